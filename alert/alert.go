@@ -0,0 +1,122 @@
+package alert
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/fingerprint"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// Alert 正在告警的错误，LogData 为第一条
+type Alert struct {
+	logstash.LogData
+	StartsAt time.Time
+	LastSeen time.Time
+	Count    int
+}
+
+// Duration 从开始到最后一次出现的时间
+func (a *Alert) Duration() time.Duration {
+	return a.LastSeen.Sub(a.StartsAt)
+}
+
+// Tracker 按指纹记录正在告警的错误，超过 quiet 时间没有再出现则认为已经恢复
+type Tracker struct {
+	lock     sync.Mutex
+	alerts   map[string]*Alert
+	quiet    time.Duration
+	resolved func(alert *Alert)
+	ticker   *time.Ticker
+	done     chan struct{}
+}
+
+// NewTracker 创建 tracker，恢复时调用 resolved
+func NewTracker(quiet time.Duration, resolved func(alert *Alert)) *Tracker {
+	t := &Tracker{
+		alerts:   make(map[string]*Alert),
+		quiet:    quiet,
+		resolved: resolved,
+		ticker:   time.NewTicker(checkInterval(quiet)),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// Update 修改恢复时间和回调，已经记录的告警保留，用于重新加载配置
+func (t *Tracker) Update(quiet time.Duration, resolved func(alert *Alert)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.quiet = quiet
+	t.resolved = resolved
+	t.ticker.Reset(checkInterval(quiet))
+}
+
+// checkInterval 检查间隔为 quiet 的一半，最少 1 秒，最多 10 秒
+func checkInterval(quiet time.Duration) time.Duration {
+	interval := quiet / 2
+	if interval > 10*time.Second {
+		interval = 10 * time.Second
+	} else if interval < time.Second {
+		interval = time.Second
+	}
+
+	return interval
+}
+
+// Observe 记录一次告警
+func (t *Tracker) Observe(logData logstash.LogData, now time.Time) {
+	fp := fingerprint.Get(logData)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	a, exists := t.alerts[fp]
+	if !exists {
+		a = &Alert{LogData: logData, StartsAt: now}
+		t.alerts[fp] = a
+	}
+	a.LastSeen = now
+	a.Count++
+}
+
+// Resolve 找出已经恢复的告警并移除
+func (t *Tracker) Resolve(now time.Time) []*Alert {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var resolved []*Alert
+	for fp, a := range t.alerts {
+		if now.Sub(a.LastSeen) >= t.quiet {
+			resolved = append(resolved, a)
+			delete(t.alerts, fp)
+		}
+	}
+
+	return resolved
+}
+
+func (t *Tracker) loop() {
+	for {
+		select {
+		case now := <-t.ticker.C:
+			alerts := t.Resolve(now)
+			t.lock.Lock()
+			resolved := t.resolved
+			t.lock.Unlock()
+			for _, a := range alerts {
+				resolved(a)
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Close 停止检查
+func (t *Tracker) Close() {
+	t.ticker.Stop()
+	close(t.done)
+}
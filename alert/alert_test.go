@@ -0,0 +1,51 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(time.Minute, func(*Alert) {})
+	defer tracker.Close()
+
+	now := time.Now()
+	timeout := logstash.LogData{Source: "/data/logs/a.log", Message: "timeout after 3000ms"}
+	tracker.Observe(timeout, now)
+	tracker.Observe(logstash.LogData{Source: "/data/logs/a.log", Message: "timeout after 5000ms"}, now.Add(30*time.Second))
+	tracker.Observe(logstash.LogData{Source: "/data/logs/a.log", Message: "user not found"}, now.Add(50*time.Second))
+
+	assert.Equal(t, len(tracker.Resolve(now.Add(80*time.Second))), 0)
+
+	resolved := tracker.Resolve(now.Add(90 * time.Second))
+	assert.Equal(t, len(resolved), 1)
+	assert.Equal(t, resolved[0].Message, timeout.Message)
+	assert.Equal(t, resolved[0].Count, 2)
+	assert.Equal(t, resolved[0].Duration(), 30*time.Second)
+
+	assert.Equal(t, len(tracker.Resolve(now.Add(110*time.Second))), 1)
+	assert.Equal(t, len(tracker.Resolve(now.Add(200*time.Second))), 0)
+}
+
+func TestTrackerUpdate(t *testing.T) {
+	tracker := NewTracker(time.Hour, func(*Alert) {})
+	defer tracker.Close()
+
+	now := time.Now()
+	tracker.Observe(logstash.LogData{Message: "timeout"}, now)
+
+	// 修改配置后保留正在告警的错误，使用新的恢复时间和回调
+	resolved := make(chan *Alert, 1)
+	tracker.Update(time.Second, func(a *Alert) { resolved <- a })
+	assert.Equal(t, len(tracker.Resolve(now.Add(time.Millisecond))), 0)
+
+	select {
+	case a := <-resolved:
+		assert.Equal(t, a.Message, "timeout")
+	case <-time.After(5 * time.Second):
+		t.Error("not resolved")
+	}
+}
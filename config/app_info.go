@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// 没有配置规则时使用的规则：/data/logs/ 下的文件名，其次是文件名去掉扩展名
+var defaultAppRules = []AppRule{
+	{Field: "source", Regex: `/data/logs/(?P<app>[^/.]+)`},
+	{Field: "source", Regex: `(?P<app>[^/.]+)[^/]*$`},
+}
+
+// AppInfo 应用名称提取规则，按顺序尝试，第一个提取到非空名称的规则生效
+type AppInfo struct {
+	Rules   []AppRule `json:"rules" mapstructure:"rules"`
+	Default string    `json:"default" mapstructure:"default"` // 都没有提取到时的名称，默认 unknown
+}
+
+// AppRule 从字段中用正则提取应用名称
+type AppRule struct {
+	Field string `json:"field" mapstructure:"field"` // 字段路径，默认 source
+	Regex string `json:"regex" mapstructure:"regex"`
+	// 名称格式，可以引用命名分组，比如 ${namespace}/${app}，
+	// 默认为 app 分组，没有 app 分组则为整个匹配
+	Format string `json:"format" mapstructure:"format"`
+	re     *regexp.Regexp
+}
+
+func (r *AppRule) compile() error {
+	if r.Field == "" {
+		r.Field = "source"
+	}
+
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return err
+	}
+	r.re = re
+
+	if r.Format == "" {
+		r.Format = "$0"
+		for _, name := range re.SubexpNames() {
+			if name == "app" {
+				r.Format = "${app}"
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *AppRule) extract(logData logstash.LogData) string {
+	value := logstash.String(logData.Field(r.Field))
+	match := r.re.FindStringSubmatchIndex(value)
+	if match == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(r.re.ExpandString(nil, r.Format, value, match)))
+}
+
+func (a *AppInfo) compile() error {
+	if len(a.Rules) == 0 {
+		a.Rules = append([]AppRule{}, defaultAppRules...)
+	}
+	if a.Default == "" {
+		a.Default = "unknown"
+	}
+
+	for i := range a.Rules {
+		if err := a.Rules[i].compile(); err != nil {
+			return fmt.Errorf("rule pos:%d %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// AppName 按照 filter 的规则提取应用名称
+func (f *Filter) AppName(logData logstash.LogData) string {
+	for i := range f.App.Rules {
+		if app := f.App.Rules[i].extract(logData); app != "" {
+			return app
+		}
+	}
+
+	return f.App.Default
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestAppName(t *testing.T) {
+	f := &Filter{}
+	assert.Nil(t, f.App.compile())
+	assert.Equal(t, f.AppName(logstash.LogData{Source: "/data/logs/console.2017-02-10.log"}), "console")
+	assert.Equal(t, f.AppName(logstash.LogData{Source: "/var/log/v1.2/api.log"}), "api")
+	assert.Equal(t, f.AppName(logstash.LogData{}), "unknown")
+
+	f = &Filter{App: AppInfo{
+		Rules: []AppRule{
+			{Field: "kubernetes.pod.name", Regex: `^(?P<app>.+)-[a-z0-9]+-[a-z0-9]{5}$`},
+			{Field: "source", Regex: `/opt/(?P<team>\w+)/(?P<app>\w+)/`, Format: "${team}-${app}"},
+		},
+		Default: "other",
+	}}
+	assert.Nil(t, f.App.compile())
+
+	pod := map[string]interface{}{"kubernetes": map[string]interface{}{"pod": map[string]interface{}{"name": "order-api-7d9f8b6c5-x2k4z"}}}
+	assert.Equal(t, f.AppName(logstash.LogData{Fields: pod}), "order-api")
+	assert.Equal(t, f.AppName(logstash.LogData{Source: "/opt/pay/gateway/logs/app.log"}), "pay-gateway")
+	assert.Equal(t, f.AppName(logstash.LogData{Source: "/data/logs/console.log"}), "other")
+
+	assert.NotNil(t, (&AppInfo{Rules: []AppRule{{Regex: "("}}}).compile())
+}
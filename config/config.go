@@ -11,10 +11,11 @@ import (
 	"regexp"
 	"sort"
 	"strings"
-	"time"
+	"text/template"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sdvdxl/go-tools/encrypt"
+	"github.com/sdvdxl/logstash-http-push/expr"
 	"github.com/spf13/viper"
 )
 
@@ -27,6 +28,9 @@ var (
 	cfg    Config
 	once   sync.Once
 	inited = false
+	loaded = false // 是否成功加载过配置
+	// reloadHooks 配置重新加载后调用
+	reloadHooks []func(cfg *Config)
 )
 
 // Get 获取配置信息
@@ -38,6 +42,11 @@ func Get() *Config {
 	return &cfg
 }
 
+// OnReload 注册配置重新加载后的回调，启动时的第一次加载不调用
+func OnReload(fn func(cfg *Config)) {
+	reloadHooks = append(reloadHooks, fn)
+}
+
 // Config 配置文件
 type Config struct {
 	MaxMailSize int                `json:"maxMailSize"`
@@ -46,7 +55,12 @@ type Config struct {
 	LogLevel    string             `json:"logLevel"`
 	Filters     []*Filter          `json:"filters"`
 	filterMap   map[string]*Filter `json:"-"`
-	TimeZone    int8               `json:"timeZone"` //时区，如果时间有偏移则加上时区，否则设置为0即可
+	TimeZone    int8               `json:"timeZone"` //时区，logstash 推送到 /push 的时间如果有偏移则加上时区，否则设置为0即可，其他接口不修正
+	Queue       QueueInfo          `json:"queue"`
+	SilenceFile string             `json:"silenceFile"` // 静默保存的文件，默认 var/silences.json
+	Receivers   []*Filter          `json:"receivers"`   // 命名的接收者，配置和 filter 相同，tags 和 levels 只用于显示
+	Route       *Route             `json:"route"`       // 路由树，配置后忽略 filters
+	GELF        GELFInfo           `json:"gelf"`
 }
 
 const filterKeyPrefix = "filter-"
@@ -135,10 +149,63 @@ func load() {
 
 func readConfig(viperReader *viper.Viper) {
 	log.Println("read config...")
-	inited = false
 	errors.Panic(viperReader.ReadInConfig())
-	errors.Panic(viperReader.Unmarshal(&cfg))
+
+	var c Config
+	errors.Panic(viperReader.Unmarshal(&c))
+
+	// 表达式有错误时，重新加载保留原来的配置，启动时退出
+	if err := c.compileExprs(); err != nil {
+		if loaded {
+			log.Println("config not reloaded,", err)
+			return
+		}
+		log.Fatalln(err)
+	}
+
+	inited = false
+	cfg = c
 	check()
+
+	reload := loaded
+	loaded = true
+	if reload {
+		for _, fn := range reloadHooks {
+			fn(&cfg)
+		}
+	}
+}
+
+// compileExprs 编译所有的表达式，返回所有的错误
+func (c *Config) compileExprs() error {
+	var errs []string
+	compile := func(name string, f *Filter) {
+		if f.Expr == "" {
+			return
+		}
+
+		e, err := expr.Compile(f.Expr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v expr %q: %v", name, f.Expr, err))
+		}
+		f.expr = e
+	}
+
+	for i, f := range c.Filters {
+		compile(fmt.Sprint("filters[", i, "]"), f)
+	}
+	for _, f := range c.Receivers {
+		compile(fmt.Sprint("receiver ", f.Name), f)
+	}
+	if c.Route != nil {
+		errs = c.Route.compileExprs("route", errs)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid expr:\n%v", strings.Join(errs, "\n"))
+	}
+
+	return nil
 }
 
 func check() {
@@ -146,9 +213,24 @@ func check() {
 	// 检查配置项目
 	nameMap := make(map[string]bool)
 	cfg.filterMap = make(map[string]*Filter)
-	filters := cfg.Filters
+
+	if cfg.SilenceFile == "" {
+		cfg.SilenceFile = "var/silences.json"
+	}
+	if cfg.Queue.Path == "" {
+		cfg.Queue.Path = "var/queue.db"
+	}
+	if cfg.Queue.Retention <= 0 {
+		cfg.Queue.Retention = 86400
+	}
+	if cfg.Queue.MaxSize <= 0 {
+		cfg.Queue.MaxSize = 10000
+	}
+
+	filters := cfg.AllFilters()
 	for i := range filters {
 		filter := filters[i]
+		isReceiver := i >= len(cfg.Filters)
 
 		//tag，
 		{
@@ -175,7 +257,17 @@ func check() {
 			}
 		}
 
-		{
+		if isReceiver {
+			filter.Name = strings.TrimSpace(filter.Name)
+			if filter.Name == "" {
+				panic(fmt.Sprint("receiver pos:", i-len(cfg.Filters), " name is empty"))
+			}
+			if _, exists := nameMap[filter.Name]; exists {
+				panic(fmt.Sprint("receiver already exists: ", filter.Name))
+			}
+
+			nameMap[filter.Name] = true
+		} else {
 			// filter name= filter-tags-levels，tags， levels 相同，则认为是同一个 filter
 			filter.Name = filterKeyPrefix + fmt.Sprintf("%v-%v", strings.Join(filter.Tags, "-"), strings.Join(filter.Levels, "-"))
 			if _, exists := nameMap[filter.Name]; exists {
@@ -185,6 +277,35 @@ func check() {
 			nameMap[filter.Name] = true
 		}
 
+		// 应用名称
+		{
+			if err := filter.App.compile(); err != nil {
+				panic(fmt.Sprint("filter ", filter.Name, " app ", err))
+			}
+		}
+
+		// 阈值
+		{
+			if filter.Rate.Count > 0 && filter.Rate.Window <= 0 {
+				panic(fmt.Sprint("filter ", filter.Name, " rate window must gt 0"))
+			}
+
+			switch filter.Rate.GroupBy = strings.ToLower(strings.TrimSpace(filter.Rate.GroupBy)); filter.Rate.GroupBy {
+			case "beat.hostname":
+				filter.Rate.GroupBy = "host"
+			case "", "host", "source", "app":
+			default:
+				panic(fmt.Sprint("filter ", filter.Name, " rate groupBy must be host, source or app: ", filter.Rate.GroupBy))
+			}
+		}
+
+		// 恢复通知
+		{
+			if filter.Resolve.Quiet <= 0 {
+				filter.Resolve.Quiet = 300
+			}
+		}
+
 		// 钉钉
 		{
 
@@ -205,10 +326,201 @@ func check() {
 				}
 
 			}
+
+			switch filter.Ding.MsgType {
+			case "":
+				filter.Ding.MsgType = "text"
+			case "text", "markdown", "actionCard":
+			default:
+				panic(fmt.Sprint("filter ", filter.Name, "ding msgType must be text, markdown or actionCard: ", filter.Ding.MsgType))
+			}
+
+			if filter.Ding.MsgType == "actionCard" && filter.Ding.ActionURL == "" {
+				panic(fmt.Sprint("filter ", filter.Name, "ding actionUrl is empty"))
+			}
+
+			if len(filter.Ding.AtLevels) == 0 {
+				filter.Ding.AtLevels = []string{"ERROR", "FATAL"}
+			}
+			for j := range filter.Ding.AtLevels {
+				filter.Ding.AtLevels[j] = strings.ToUpper(strings.TrimSpace(filter.Ding.AtLevels[j]))
+			}
+		}
+
+		// slack
+		{
+			if filter.Slack.MatchRegexText != "" {
+				filter.Slack.MatchRegex = regexp.MustCompile(filter.Slack.MatchRegexText)
+			}
+
+			for j := range filter.Slack.Senders {
+				if filter.Slack.Senders[j].WebhookURL == "" {
+					panic(fmt.Sprint("filter ", filter.Name, "slack pos:", j, " webhookUrl is empty"))
+				}
+			}
+		}
+
+		// 飞书
+		{
+			if filter.Feishu.MatchRegexText != "" {
+				filter.Feishu.MatchRegex = regexp.MustCompile(filter.Feishu.MatchRegexText)
+			}
+
+			for j := range filter.Feishu.Senders {
+				if filter.Feishu.Senders[j].WebhookURL == "" {
+					panic(fmt.Sprint("filter ", filter.Name, "feishu pos:", j, " webhookUrl is empty"))
+				}
+			}
+		}
+
+		// 企业微信
+		{
+			if filter.WeCom.MatchRegexText != "" {
+				filter.WeCom.MatchRegex = regexp.MustCompile(filter.WeCom.MatchRegexText)
+			}
+
+			filter.WeCom.MsgType = strings.ToLower(strings.TrimSpace(filter.WeCom.MsgType))
+			if filter.WeCom.MsgType == "" {
+				filter.WeCom.MsgType = "text"
+			} else if filter.WeCom.MsgType != "text" && filter.WeCom.MsgType != "markdown" {
+				panic(fmt.Sprint("filter ", filter.Name, "wecom msgType must be text or markdown: ", filter.WeCom.MsgType))
+			}
+
+			if filter.WeCom.MaxLength <= 0 || filter.WeCom.MaxLength > 4096 {
+				log.Println("wecom maxLength default set to: 4096")
+				filter.WeCom.MaxLength = 4096
+			}
+
+			for j := range filter.WeCom.Senders {
+				if filter.WeCom.Senders[j].WebhookURL == "" {
+					panic(fmt.Sprint("filter ", filter.Name, "wecom pos:", j, " webhookUrl is empty"))
+				}
+			}
+		}
+
+		// webhook
+		{
+			if filter.Webhook.MatchRegexText != "" {
+				filter.Webhook.MatchRegex = regexp.MustCompile(filter.Webhook.MatchRegexText)
+			}
+
+			if filter.Webhook.Timeout <= 0 {
+				filter.Webhook.Timeout = 10
+			}
+
+			if filter.Webhook.Retries == nil {
+				retries := 3
+				filter.Webhook.Retries = &retries
+			} else if *filter.Webhook.Retries < 0 {
+				*filter.Webhook.Retries = 0
+			}
+
+			for j := range filter.Webhook.Senders {
+				w := &filter.Webhook.Senders[j]
+				if w.URL == "" {
+					panic(fmt.Sprint("filter ", filter.Name, "webhook pos:", j, " url is empty"))
+				}
+
+				w.Method = strings.ToUpper(strings.TrimSpace(w.Method))
+				if w.Method == "" {
+					w.Method = "POST"
+				}
+
+				w.BodyTemplate = template.Must(template.New(fmt.Sprint(filter.Name, "-webhook-", j)).Funcs(WebhookFuncs).Parse(w.Body))
+			}
+		}
+
+		// pagerduty
+		{
+			if filter.PagerDuty.MatchRegexText != "" {
+				filter.PagerDuty.MatchRegex = regexp.MustCompile(filter.PagerDuty.MatchRegexText)
+			}
+
+			if filter.PagerDuty.URL == "" {
+				filter.PagerDuty.URL = "https://events.pagerduty.com/v2/enqueue"
+			}
+
+			for j := range filter.PagerDuty.Senders {
+				if filter.PagerDuty.Senders[j].RoutingKey == "" {
+					panic(fmt.Sprint("filter ", filter.Name, "pagerduty pos:", j, " routingKey is empty"))
+				}
+			}
+		}
+
+		// telegram
+		{
+			if filter.Telegram.MatchRegexText != "" {
+				filter.Telegram.MatchRegex = regexp.MustCompile(filter.Telegram.MatchRegexText)
+			}
+
+			if filter.Telegram.Enable && (filter.Telegram.BotToken == "" || len(filter.Telegram.ChatIDs) == 0) {
+				panic(fmt.Sprint("filter ", filter.Name, "telegram botToken or chatIds is empty"))
+			}
+		}
+
+		// teams
+		{
+			if filter.Teams.MatchRegexText != "" {
+				filter.Teams.MatchRegex = regexp.MustCompile(filter.Teams.MatchRegexText)
+			}
+
+			if filter.Teams.Duration == 0 {
+				log.Println("teams send duration default set to: 60s")
+				filter.Teams.Duration = 60
+			} else if filter.Teams.Duration < 5 {
+				panic("teams duration is too small, must gte 5")
+			}
+
+			if filter.Teams.MaxSize <= 0 {
+				filter.Teams.MaxSize = 20
+			}
+
+			for j := range filter.Teams.Senders {
+				if filter.Teams.Senders[j].WebhookURL == "" {
+					panic(fmt.Sprint("filter ", filter.Name, "teams pos:", j, " webhookUrl is empty"))
+				}
+			}
 		}
 
-		// mail
+		// 通知
 		{
+			if len(filter.Notifiers) == 0 {
+				if filter.Ding.Enable {
+					filter.Notifiers = append(filter.Notifiers, "ding")
+				}
+				if filter.Mail.Enable {
+					filter.Notifiers = append(filter.Notifiers, "mail")
+				}
+				if filter.Slack.Enable {
+					filter.Notifiers = append(filter.Notifiers, "slack")
+				}
+				if filter.Feishu.Enable {
+					filter.Notifiers = append(filter.Notifiers, "feishu")
+				}
+				if filter.WeCom.Enable {
+					filter.Notifiers = append(filter.Notifiers, "wecom")
+				}
+				if filter.Webhook.Enable {
+					filter.Notifiers = append(filter.Notifiers, "webhook")
+				}
+				if filter.PagerDuty.Enable {
+					filter.Notifiers = append(filter.Notifiers, "pagerduty")
+				}
+				if filter.Telegram.Enable {
+					filter.Notifiers = append(filter.Notifiers, "telegram")
+				}
+				if filter.Teams.Enable {
+					filter.Notifiers = append(filter.Notifiers, "teams")
+				}
+			}
+
+			for j := range filter.Notifiers {
+				filter.Notifiers[j] = strings.ToLower(strings.TrimSpace(filter.Notifiers[j]))
+			}
+		}
+
+		// mail，只有使用了邮件通知才检查
+		if filter.usesNotifier("mail") {
 			if len(filter.Mail.ToPersons) == 0 {
 				panic(fmt.Sprint("filter ", filter.Name, "email toPersons is empty, disabled"))
 			}
@@ -237,14 +549,59 @@ func check() {
 			} else if filter.Mail.Duration < 5 {
 				panic("duration is too small, must gte 5")
 			}
+		}
+
+		// 维护时间段和安静时段
+		{
+			for j := range filter.Maintenance {
+				if err := filter.Maintenance[j].compile(); err != nil {
+					panic(fmt.Sprint("filter ", filter.Name, " maintenance pos:", j, " ", err))
+				}
+			}
 
-			filter.Mail.Ticker = time.NewTicker(time.Second * time.Duration(filter.Mail.Duration))
+			for j := range filter.QuietHours.Windows {
+				if err := filter.QuietHours.Windows[j].compile(); err != nil {
+					panic(fmt.Sprint("filter ", filter.Name, " quietHours pos:", j, " ", err))
+				}
+			}
+
+			// 默认只静默即时通知，聚合的通知照常积累
+			if len(filter.QuietHours.Notifiers) == 0 {
+				for _, n := range filter.Notifiers {
+					if n != "mail" && n != "teams" {
+						filter.QuietHours.Notifiers = append(filter.QuietHours.Notifiers, n)
+					}
+				}
+			}
+			for j := range filter.QuietHours.Notifiers {
+				filter.QuietHours.Notifiers[j] = strings.ToLower(strings.TrimSpace(filter.QuietHours.Notifiers[j]))
+			}
+		}
+		if isReceiver {
+			log.Println("receiver", filter.Name, "inited")
+			continue
 		}
+
 		log.Println("filter", filter.Name, "inited")
 		cfg.filterMap[filter.Name] = filter
 
 	}
 
+	// 路由
+	if cfg.Route != nil {
+		receivers := make(map[string]*Filter, len(cfg.Receivers))
+		for _, r := range cfg.Receivers {
+			receivers[r.Name] = r
+		}
+
+		if err := cfg.Route.compile(receivers, nil); err != nil {
+			panic(fmt.Sprint("route: ", err))
+		}
+		if len(cfg.Filters) > 0 {
+			log.Println("route is set, filters are ignored")
+		}
+	}
+
 	inited = true
 	log.Println("config inited")
 }
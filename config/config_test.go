@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+func TestCheckMail(t *testing.T) {
+	old := cfg
+	defer func() { cfg = old }()
+
+	// 没有使用邮件通知的 receiver 不需要配置 toPersons
+	cfg = Config{
+		Receivers: []*Filter{{Name: "ops", Notifiers: []string{"ding"}, Ding: DingInfo{Senders: []DingSender{{Token: "t"}}}}},
+		Route:     &Route{Receiver: "ops"},
+	}
+	check()
+	assert.Equal(t, cfg.Receivers[0].Mail.Duration, 0)
+
+	cfg = Config{
+		Receivers: []*Filter{{Name: "ops", Notifiers: []string{"mail"}}},
+		Route:     &Route{Receiver: "ops"},
+	}
+	assert.Panic(t, check)
+
+	cfg.Receivers[0].Mail.ToPersons = []string{"ops@example.com"}
+	check()
+	assert.Equal(t, cfg.Receivers[0].Mail.Duration, 60)
+}
+
+func TestCheckWebhookRetries(t *testing.T) {
+	old := cfg
+	defer func() { cfg = old }()
+
+	// 不设置默认重试 3 次，0 不重试
+	zero := 0
+	cfg = Config{
+		Receivers: []*Filter{{Name: "a"}, {Name: "b", Webhook: WebhookInfo{Retries: &zero}}},
+		Route:     &Route{Receiver: "a"},
+	}
+	check()
+	assert.Equal(t, *cfg.Receivers[0].Webhook.Retries, 3)
+	assert.Equal(t, *cfg.Receivers[1].Webhook.Retries, 0)
+}
@@ -9,9 +9,19 @@ type DingInfo struct {
 	Enable         bool           `json:"enable" mapstructure:"enable"`
 	MatchRegexText string         `json:"matchRegex" mapstructure:"matchRegex"`
 	MatchRegex     *regexp.Regexp `json:"-" mapstructure:"-"`
-	Senders        []DingSender   `json:"-" mapstructure:"senders"`
+	MsgType        string         `json:"msgType" mapstructure:"msgType"` // text，markdown 或者 actionCard，默认 text
+	// actionCard 按钮标题和跳转链接，比如 kibana 的地址
+	ActionTitle string `json:"actionTitle" mapstructure:"actionTitle"`
+	ActionURL   string `json:"actionUrl" mapstructure:"actionUrl"`
+	// @ 的手机号，只有日志级别在 AtLevels 中才会 @，默认 ERROR 和 FATAL，actionCard 不支持 @
+	AtMobiles []string     `json:"atMobiles" mapstructure:"atMobiles"`
+	IsAtAll   bool         `json:"isAtAll" mapstructure:"isAtAll"`
+	AtLevels  []string     `json:"atLevels" mapstructure:"atLevels"`
+	Senders   []DingSender `json:"-" mapstructure:"senders"`
 }
 
 type DingSender struct {
 	Token string `json:"token" mapstructure:"token"`
+	// 加签的密钥，为空则不签名
+	Secret string `json:"secret" mapstructure:"secret"`
 }
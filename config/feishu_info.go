@@ -0,0 +1,21 @@
+package config
+
+import "regexp"
+
+// FeishuInfo 飞书自定义机器人
+type FeishuInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64          `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool           `json:"enable" mapstructure:"enable"`
+	MatchRegexText string         `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp `json:"-" mapstructure:"-"`
+	Interval       int            `json:"interval" mapstructure:"interval"` // 秒，每隔 interval 秒最多发送 limit 条，默认 3 秒
+	Limit          int            `json:"limit" mapstructure:"limit"`       // 默认 1 条
+	Senders        []FeishuSender `json:"senders" mapstructure:"senders"`
+}
+
+type FeishuSender struct {
+	WebhookURL string `json:"webhookUrl" mapstructure:"webhookUrl"`
+	// 签名校验的密钥，为空则不签名
+	Secret string `json:"secret" mapstructure:"secret"`
+}
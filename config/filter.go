@@ -1,14 +1,36 @@
 package config
 
+import (
+	"github.com/sdvdxl/logstash-http-push/expr"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
 // Filter log 过滤
 type Filter struct {
-	Name           string   `json:"-" mapstructure:"-"`
+	Name           string   `json:"name" mapstructure:"name"`                     // filters 的 name 根据 tags 和 levels 生成，receivers 需要配置
 	IgnoreContains []string `json:"ignoreContains" mapstructure:"ignoreContains"` // 忽略的列表，普通字符串，如果包含其中一个则忽略，or 的关系
 	lastMailIndex  int
-	Levels         []string `json:"levels" mapstructure:"levels"`
-	Tags           []string `json:"tags" mapstructure:"tags"`
-	Ding           DingInfo `json:"ding" mapstructure:"ding"` // 钉钉 机器人token
-	Mail           MailInfo `json:"mail" mapstructure:"mail"`
+	Levels         []string      `json:"levels" mapstructure:"levels"`
+	Tags           []string      `json:"tags" mapstructure:"tags"`
+	Expr           string        `json:"expr" mapstructure:"expr"` // 过滤表达式，满足才通知，见 expr 包
+	App            AppInfo       `json:"app" mapstructure:"app"`   // 应用名称提取规则
+	Rate           RateInfo      `json:"rate" mapstructure:"rate"` // 阈值，count 为 0 则每次匹配都发送
+	Resolve        ResolveInfo   `json:"resolve" mapstructure:"resolve"`
+	Maintenance    []TimeWindow  `json:"maintenance" mapstructure:"maintenance"` // 维护时间段，时间段内匹配的 log 都不通知
+	QuietHours     QuietInfo     `json:"quietHours" mapstructure:"quietHours"`
+	Ding           DingInfo      `json:"ding" mapstructure:"ding"` // 钉钉 机器人token
+	Mail           MailInfo      `json:"mail" mapstructure:"mail"`
+	Slack          SlackInfo     `json:"slack" mapstructure:"slack"`
+	Feishu         FeishuInfo    `json:"feishu" mapstructure:"feishu"` // 飞书机器人
+	WeCom          WeComInfo     `json:"wecom" mapstructure:"wecom"`   // 企业微信群机器人
+	Webhook        WebhookInfo   `json:"webhook" mapstructure:"webhook"`
+	PagerDuty      PagerDutyInfo `json:"pagerduty" mapstructure:"pagerduty"`
+	Telegram       TelegramInfo  `json:"telegram" mapstructure:"telegram"`
+	Teams          TeamsInfo     `json:"teams" mapstructure:"teams"`
+	// 启用的通知类型，比如 ["ding", "mail"]，为空则根据各通知的 enable 决定
+	Notifiers []string `json:"notifiers" mapstructure:"notifiers"`
+
+	expr *expr.Expr
 }
 
 func (f *Filter) GetMail() MailSender {
@@ -19,3 +41,19 @@ func (f *Filter) GetNextMail() MailSender {
 	f.lastMailIndex++
 	return f.Mail.Senders[f.lastMailIndex%len(f.Mail.Senders)]
 }
+
+// MatchExpr log 是否满足 filter 的表达式，没有配置表达式则满足
+func (f *Filter) MatchExpr(logData logstash.LogData) bool {
+	return f.expr == nil || f.expr.Match(logData)
+}
+
+// usesNotifier 是否启用了 typ 类型的通知
+func (f *Filter) usesNotifier(typ string) bool {
+	for _, n := range f.Notifiers {
+		if n == typ {
+			return true
+		}
+	}
+
+	return false
+}
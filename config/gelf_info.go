@@ -0,0 +1,7 @@
+package config
+
+// GELFInfo GELF 监听地址，比如 ":12201"，为空不监听，修改后需要重启
+type GELFInfo struct {
+	UDP string `json:"udp" mapstructure:"udp"` // 支持分块和 gzip，zlib 压缩
+	TCP string `json:"tcp" mapstructure:"tcp"` // 每条消息以 \0 结尾
+}
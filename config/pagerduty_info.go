@@ -0,0 +1,20 @@
+package config
+
+import "regexp"
+
+// PagerDutyInfo PagerDuty Events API v2
+type PagerDutyInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64             `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool              `json:"enable" mapstructure:"enable"`
+	MatchRegexText string            `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp    `json:"-" mapstructure:"-"`
+	URL            string            `json:"url" mapstructure:"url"` // 默认 https://events.pagerduty.com/v2/enqueue
+	Client         string            `json:"client" mapstructure:"client"`
+	ClientURL      string            `json:"clientUrl" mapstructure:"clientUrl"`
+	Senders        []PagerDutySender `json:"senders" mapstructure:"senders"`
+}
+
+type PagerDutySender struct {
+	RoutingKey string `json:"routingKey" mapstructure:"routingKey"` // integration key
+}
@@ -0,0 +1,9 @@
+package config
+
+// QueueInfo 持久化队列，重启后未发送的消息可以继续发送
+type QueueInfo struct {
+	Enable    bool   `json:"enable" mapstructure:"enable"`
+	Path      string `json:"path" mapstructure:"path"`           // 队列文件，默认 var/queue.db
+	Retention int64  `json:"retention" mapstructure:"retention"` // 秒，超过这个时间的消息启动时丢弃，默认 86400
+	MaxSize   int    `json:"maxSize" mapstructure:"maxSize"`     // 每个通知队列最多保留的条数，默认 10000
+}
@@ -0,0 +1,9 @@
+package config
+
+// RateInfo 阈值告警，window 秒内匹配到 count 次以上才发送通知
+type RateInfo struct {
+	Count  int `json:"count" mapstructure:"count"`
+	Window int `json:"window" mapstructure:"window"` // 秒
+	// 分组计数的字段，为空则不分组，可选 host（beat.hostname），source 和 app
+	GroupBy string `json:"groupBy" mapstructure:"groupBy"`
+}
@@ -0,0 +1,7 @@
+package config
+
+// ResolveInfo 恢复通知，告警的错误超过 quiet 秒没有再出现则发送恢复通知
+type ResolveInfo struct {
+	Enable bool `json:"enable" mapstructure:"enable"`
+	Quiet  int  `json:"quiet" mapstructure:"quiet"` // 秒，默认 300
+}
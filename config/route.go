@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sdvdxl/logstash-http-push/expr"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// Route 路由，匹配的 log 发送到 receiver，子路由匹配则交给子路由处理，
+// 第一个匹配的子路由生效，除非子路由设置了 continue
+type Route struct {
+	Receiver string            `json:"receiver" mapstructure:"receiver"` // 为空则继承父路由的 receiver
+	Tags     []string          `json:"tags" mapstructure:"tags"`         // log 需要包含所有的 tag
+	Match    map[string]string `json:"match" mapstructure:"match"`       // 字段完全相等，忽略大小写
+	MatchRe  map[string]string `json:"matchRe" mapstructure:"matchRe"`   // 字段匹配正则，正则需要匹配整个字段，字段为路径，比如 kubernetes.namespace
+	Expr     string            `json:"expr" mapstructure:"expr"`         // 过滤表达式，见 expr 包
+	Continue bool              `json:"continue" mapstructure:"continue"` // 匹配后是否继续匹配后面的兄弟路由
+	Routes   []*Route          `json:"routes" mapstructure:"routes"`
+
+	receiver *Filter
+	matchRe  map[string]*regexp.Regexp
+	expr     *expr.Expr
+}
+
+// compile 检查 receiver 并编译正则，parent 为父路由的 receiver
+func (r *Route) compile(receivers map[string]*Filter, parent *Filter) error {
+	if r.Receiver == "" {
+		r.receiver = parent
+	} else if r.receiver = receivers[r.Receiver]; r.receiver == nil {
+		return fmt.Errorf("route receiver not found: %v", r.Receiver)
+	}
+	if r.receiver == nil {
+		return fmt.Errorf("root route receiver is empty")
+	}
+
+	for i := range r.Tags {
+		r.Tags[i] = strings.ToUpper(strings.TrimSpace(r.Tags[i]))
+	}
+
+	r.matchRe = make(map[string]*regexp.Regexp, len(r.MatchRe))
+	for name, pattern := range r.MatchRe {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("route matchRe %v: %v", name, err)
+		}
+		r.matchRe[name] = re
+	}
+
+	for _, child := range r.Routes {
+		if err := child.compile(receivers, r.receiver); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Route) matches(logData logstash.LogData) bool {
+	for _, tag := range r.Tags {
+		found := false
+		for _, t := range logData.Tags {
+			if strings.ToUpper(t) == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for name, value := range r.Match {
+		if v := logstash.String(logData.Field(name)); !strings.EqualFold(v, value) {
+			return false
+		}
+	}
+
+	for name, re := range r.matchRe {
+		if v := logstash.String(logData.Field(name)); !re.MatchString(v) {
+			return false
+		}
+	}
+
+	return r.expr == nil || r.expr.Match(logData)
+}
+
+// route 返回匹配的 receiver，没有子路由匹配则使用自己的 receiver
+func (r *Route) route(logData logstash.LogData) []*Filter {
+	if !r.matches(logData) {
+		return nil
+	}
+
+	var result []*Filter
+	for _, child := range r.Routes {
+		matched := child.route(logData)
+		if len(matched) == 0 {
+			continue
+		}
+
+		result = append(result, matched...)
+		if !child.Continue {
+			break
+		}
+	}
+
+	if len(result) == 0 {
+		result = []*Filter{r.receiver}
+	}
+
+	return result
+}
+
+// Match 返回 log 需要发送的 filter，配置了 route 则按路由树匹配，否则按 filters 的 tags 和 levels 匹配
+func (cfg *Config) Match(logData logstash.LogData) []*Filter {
+	if cfg.Route == nil {
+		return cfg.GetFilter(logData.Tags, logData.Level)
+	}
+
+	// 同一个 receiver 只发送一次
+	matched := cfg.Route.route(logData)
+	result := make([]*Filter, 0, len(matched))
+	for _, f := range matched {
+		found := false
+		for _, r := range result {
+			if r == f {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// AllFilters 所有的 filter，包括 receivers，用于检查配置
+func (cfg *Config) AllFilters() []*Filter {
+	filters := make([]*Filter, 0, len(cfg.Filters)+len(cfg.Receivers))
+	filters = append(filters, cfg.Filters...)
+	return append(filters, cfg.Receivers...)
+}
+
+// ActiveFilters 需要创建通知的 filter，配置了 route 则为路由树用到的 receivers，否则为 filters
+func (cfg *Config) ActiveFilters() []*Filter {
+	if cfg.Route == nil {
+		return cfg.Filters
+	}
+
+	return cfg.Route.receivers(nil)
+}
+
+// receivers 路由树中用到的 receiver，不重复
+func (r *Route) receivers(result []*Filter) []*Filter {
+	found := false
+	for _, f := range result {
+		if f == r.receiver {
+			found = true
+			break
+		}
+	}
+	if !found {
+		result = append(result, r.receiver)
+	}
+
+	for _, child := range r.Routes {
+		result = child.receivers(result)
+	}
+
+	return result
+}
+
+// compileExprs 编译路由树中的表达式，path 为路由的位置，用于错误信息
+func (r *Route) compileExprs(path string, errs []string) []string {
+	if r.Expr != "" {
+		e, err := expr.Compile(r.Expr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v expr %q: %v", path, r.Expr, err))
+		}
+		r.expr = e
+	}
+
+	for i, child := range r.Routes {
+		errs = child.compileExprs(fmt.Sprintf("%v.routes[%d]", path, i), errs)
+	}
+
+	return errs
+}
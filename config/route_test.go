@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestRoute(t *testing.T) {
+	def, ops, dba := &Filter{Name: "default"}, &Filter{Name: "ops"}, &Filter{Name: "dba"}
+	receivers := map[string]*Filter{"default": def, "ops": ops, "dba": dba}
+
+	cfg := Config{Route: &Route{
+		Receiver: "default",
+		Routes: []*Route{
+			{Tags: []string{"mysql"}, Receiver: "dba", Continue: true},
+			{MatchRe: map[string]string{"level": "ERROR|FATAL"}, Receiver: "ops", Routes: []*Route{
+				{Match: map[string]string{"host": "prod-1"}},
+			}},
+			{Match: map[string]string{"level": "error"}, Receiver: "dba"},
+		},
+	}}
+	assert.Nil(t, cfg.Route.compile(receivers, nil))
+
+	assert.Equal(t, cfg.Match(logstash.LogData{Level: "INFO"}), []*Filter{def})
+	assert.Equal(t, cfg.Match(logstash.LogData{Level: "ERROR"}), []*Filter{ops})
+	assert.Equal(t, cfg.Match(logstash.LogData{Level: "ERROR", Beat: logstash.Beat{Hostname: "prod-1"}}), []*Filter{ops})
+	assert.Equal(t, cfg.Match(logstash.LogData{Level: "FATAL", Tags: []string{"mysql"}}), []*Filter{dba, ops})
+	assert.Equal(t, cfg.Match(logstash.LogData{Level: "INFO", Tags: []string{"mysql"}}), []*Filter{dba})
+
+	// 原始字段
+	cfg.Route.Routes[0].Routes = []*Route{{Match: map[string]string{"kubernetes.namespace": "db"}, Receiver: "ops"}}
+	assert.Nil(t, cfg.Route.compile(receivers, nil))
+	k8s := map[string]interface{}{"kubernetes": map[string]interface{}{"namespace": "db"}}
+	assert.Equal(t, cfg.Match(logstash.LogData{Tags: []string{"mysql"}, Fields: k8s}), []*Filter{ops})
+
+	// 只有路由树用到的 receiver 需要创建通知
+	cfg.Filters = []*Filter{{Name: "filter"}}
+	assert.Equal(t, cfg.ActiveFilters(), []*Filter{def, dba, ops})
+	cfg.Route = &Route{Receiver: "ops", Routes: []*Route{{Tags: []string{"mysql"}}}}
+	assert.Nil(t, cfg.Route.compile(receivers, nil))
+	assert.Equal(t, cfg.ActiveFilters(), []*Filter{ops})
+	cfg.Route = nil
+	assert.Equal(t, cfg.ActiveFilters(), cfg.Filters)
+
+	assert.NotNil(t, (&Route{Receiver: "none"}).compile(receivers, nil))
+	assert.NotNil(t, (&Route{}).compile(receivers, nil))
+	assert.NotNil(t, (&Route{Receiver: "ops", MatchRe: map[string]string{"level": "("}}).compile(receivers, nil))
+}
+
+func TestCompileExprs(t *testing.T) {
+	cfg := Config{
+		Filters: []*Filter{{Expr: `level == "ERROR"`}, {Expr: `level = "ERROR"`}},
+		Route:   &Route{Routes: []*Route{{Expr: `message =~ "("`}}},
+	}
+
+	err := cfg.compileExprs()
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), `filters[1] expr "level = \"ERROR\"": col 7: unexpected character '='`), err)
+	assert.True(t, strings.Contains(err.Error(), `route.routes[0] expr`), err)
+
+	assert.True(t, cfg.Filters[0].MatchExpr(logstash.LogData{Level: "ERROR"}))
+	assert.False(t, cfg.Filters[0].MatchExpr(logstash.LogData{Level: "INFO"}))
+}
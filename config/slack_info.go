@@ -0,0 +1,23 @@
+package config
+
+import "regexp"
+
+// SlackInfo slack incoming webhook
+type SlackInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64          `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool           `json:"enable" mapstructure:"enable"`
+	MatchRegexText string         `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp `json:"-" mapstructure:"-"`
+	Interval       int            `json:"interval" mapstructure:"interval"` // 秒，每隔 interval 秒最多发送 limit 条，默认和钉钉一样 3 秒
+	Limit          int            `json:"limit" mapstructure:"limit"`       // 默认 1 条
+	Senders        []SlackSender  `json:"senders" mapstructure:"senders"`
+}
+
+type SlackSender struct {
+	WebhookURL string `json:"webhookUrl" mapstructure:"webhookUrl"`
+	Channel    string `json:"channel" mapstructure:"channel"`
+	Username   string `json:"username" mapstructure:"username"`
+	IconEmoji  string `json:"iconEmoji" mapstructure:"iconEmoji"`
+	IconURL    string `json:"iconUrl" mapstructure:"iconUrl"`
+}
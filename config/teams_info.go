@@ -0,0 +1,19 @@
+package config
+
+import "regexp"
+
+// TeamsInfo Microsoft Teams incoming webhook，和邮件一样聚合后发送
+type TeamsInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64          `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool           `json:"enable" mapstructure:"enable"`
+	MatchRegexText string         `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp `json:"-" mapstructure:"-"`
+	Duration       int            `json:"duration" mapstructure:"duration"` //秒，每隔 duration 秒批量发送一次，默认 60
+	MaxSize        int            `json:"maxSize" mapstructure:"maxSize"`   // 每次最多发送的日志条数，默认 20，teams 消息最大 28KB
+	Senders        []TeamsSender  `json:"senders" mapstructure:"senders"`
+}
+
+type TeamsSender struct {
+	WebhookURL string `json:"webhookUrl" mapstructure:"webhookUrl"`
+}
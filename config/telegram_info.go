@@ -0,0 +1,16 @@
+package config
+
+import "regexp"
+
+// TelegramInfo telegram bot
+type TelegramInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64          `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool           `json:"enable" mapstructure:"enable"`
+	MatchRegexText string         `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp `json:"-" mapstructure:"-"`
+	BotToken       string         `json:"botToken" mapstructure:"botToken"`
+	ChatIDs        []string       `json:"chatIds" mapstructure:"chatIds"`
+	Interval       int            `json:"interval" mapstructure:"interval"` // 秒，每隔 interval 秒最多发送 limit 条，默认 3 秒
+	Limit          int            `json:"limit" mapstructure:"limit"`       // 默认 1 条
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+// TimeWindow 每周重复的时间段，比如周日 02:00-04:00，end 小于 start 表示跨过零点
+type TimeWindow struct {
+	Weekdays []string `json:"weekdays" mapstructure:"weekdays"` // Mon，Tue ... Sun，为空表示每天
+	Start    string   `json:"start" mapstructure:"start"`       // 15:04
+	End      string   `json:"end" mapstructure:"end"`
+	TimeZone string   `json:"timeZone" mapstructure:"timeZone"` // IANA 时区，比如 Asia/Shanghai，默认本地时区
+
+	location *time.Location
+	days     [7]bool
+	start    int // 从零点开始的分钟数
+	end      int
+}
+
+// QuietInfo 安静时段，时段内 notifiers 中的通知不发送，其他通知（比如邮件聚合）不受影响
+type QuietInfo struct {
+	Windows []TimeWindow `json:"windows" mapstructure:"windows"`
+	// 需要静默的通知类型，默认除了 mail 和 teams 以外的所有通知
+	Notifiers []string `json:"notifiers" mapstructure:"notifiers"`
+}
+
+// Contains t 是否在时间段内
+func (w *TimeWindow) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+
+	// 跨过零点，零点以后的部分属于前一天的时间段
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return minute < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+func (w *TimeWindow) compile() error {
+	// LoadLocation("") 返回的是 UTC，为空时使用本地时区
+	w.location = time.Local
+	if w.TimeZone != "" {
+		loc, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return err
+		}
+		w.location = loc
+	}
+
+	var err error
+
+	if w.start, err = parseMinute(w.Start); err != nil {
+		return err
+	}
+	if w.end, err = parseMinute(w.End); err != nil {
+		return err
+	}
+	if w.start == w.end {
+		return fmt.Errorf("time window start equals end: %v", w.Start)
+	}
+
+	if len(w.Weekdays) == 0 {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	for _, d := range w.Weekdays {
+		name := strings.ToUpper(strings.TrimSpace(d))
+		if len(name) > 3 {
+			name = name[:3]
+		}
+		day, exists := weekdays[name]
+		if !exists {
+			return fmt.Errorf("unknown weekday: %v", d)
+		}
+		w.days[day] = true
+	}
+
+	return nil
+}
+
+func parseMinute(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("time must be HH:MM: %v", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// InMaintenance 是否在维护时间段内
+func (f *Filter) InMaintenance(t time.Time) bool {
+	for i := range f.Maintenance {
+		if f.Maintenance[i].Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InQuietHours 类型为 typ 的通知在 t 时是否需要静默
+func (f *Filter) InQuietHours(typ string, t time.Time) bool {
+	found := false
+	for _, n := range f.QuietHours.Notifiers {
+		if n == typ {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	for i := range f.QuietHours.Windows {
+		if f.QuietHours.Windows[i].Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+func TestTimeWindow(t *testing.T) {
+	w := TimeWindow{Weekdays: []string{"Sun"}, Start: "02:00", End: "04:00", TimeZone: "Asia/Shanghai"}
+	assert.Nil(t, w.compile())
+
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	assert.True(t, w.Contains(time.Date(2018, 1, 7, 2, 0, 0, 0, loc))) // 周日
+	assert.True(t, w.Contains(time.Date(2018, 1, 6, 18, 30, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2018, 1, 7, 4, 0, 0, 0, loc)))
+	assert.False(t, w.Contains(time.Date(2018, 1, 8, 3, 0, 0, 0, loc)))
+
+	// 跨过零点，周五 23:00 到周六 07:00
+	w = TimeWindow{Weekdays: []string{"friday"}, Start: "23:00", End: "07:00", TimeZone: "Asia/Shanghai"}
+	assert.Nil(t, w.compile())
+	assert.True(t, w.Contains(time.Date(2018, 1, 5, 23, 30, 0, 0, loc)))
+	assert.True(t, w.Contains(time.Date(2018, 1, 6, 6, 59, 0, 0, loc)))
+	assert.False(t, w.Contains(time.Date(2018, 1, 5, 6, 0, 0, 0, loc)))
+
+	// 默认本地时区
+	w = TimeWindow{Start: "02:00", End: "04:00"}
+	assert.Nil(t, w.compile())
+	assert.Equal(t, w.location, time.Local)
+
+	assert.NotNil(t, (&TimeWindow{Start: "2:00pm", End: "04:00"}).compile())
+	assert.NotNil(t, (&TimeWindow{Weekdays: []string{"xyz"}, Start: "02:00", End: "04:00"}).compile())
+	assert.NotNil(t, (&TimeWindow{Start: "02:00", End: "04:00", TimeZone: "Mars/Base"}).compile())
+}
+
+func TestQuietHours(t *testing.T) {
+	f := Filter{QuietHours: QuietInfo{
+		Windows:   []TimeWindow{{Start: "22:00", End: "08:00", TimeZone: "UTC"}},
+		Notifiers: []string{"ding"},
+	}}
+	assert.Nil(t, f.QuietHours.Windows[0].compile())
+
+	night := time.Date(2018, 1, 5, 23, 0, 0, 0, time.UTC)
+	assert.True(t, f.InQuietHours("ding", night))
+	assert.False(t, f.InQuietHours("mail", night))
+	assert.False(t, f.InQuietHours("ding", time.Date(2018, 1, 5, 12, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+	"text/template"
+)
+
+// WebhookInfo 自定义 webhook，用模板生成请求内容
+type WebhookInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64           `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool            `json:"enable" mapstructure:"enable"`
+	MatchRegexText string          `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp  `json:"-" mapstructure:"-"`
+	Timeout        int             `json:"timeout" mapstructure:"timeout"` // 秒，默认 10
+	Retries        *int            `json:"retries" mapstructure:"retries"` // 5xx 或者网络错误重试次数，不设置为 3，0 不重试，每次间隔翻倍
+	Senders        []WebhookSender `json:"senders" mapstructure:"senders"`
+}
+
+type WebhookSender struct {
+	URL     string            `json:"url" mapstructure:"url"`
+	Method  string            `json:"method" mapstructure:"method"` // 默认 POST
+	Headers map[string]string `json:"headers" mapstructure:"headers"`
+	// text/template 模板，数据为 LogData，可以用 json 函数转义，比如 {"msg": {{json .Message}}}，
+	// 用 field 函数获取原始字段，比如 {"pod": {{json (field "kubernetes.pod.name")}}}
+	Body         string             `json:"body" mapstructure:"body"`
+	BodyTemplate *template.Template `json:"-" mapstructure:"-"`
+}
+
+// WebhookFuncs webhook 模板可以使用的函数
+var WebhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// 渲染时替换为当前 log 的字段
+	"field": func(path string) interface{} { return "" },
+}
@@ -0,0 +1,23 @@
+package config
+
+import "regexp"
+
+// WeComInfo 企业微信群机器人
+type WeComInfo struct {
+	// 跟现在比较，如果超过了这个时间则忽略不发送
+	IgnoreIfGtSecs int64          `json:"ignoreIfGtSecs" mapstructure:"ignoreIfGtSecs"`
+	Enable         bool           `json:"enable" mapstructure:"enable"`
+	MatchRegexText string         `json:"matchRegex" mapstructure:"matchRegex"`
+	MatchRegex     *regexp.Regexp `json:"-" mapstructure:"-"`
+	MsgType        string         `json:"msgType" mapstructure:"msgType"`     // text 或者 markdown，默认 text
+	MaxLength      int            `json:"maxLength" mapstructure:"maxLength"` // 消息最大字节数，默认 4096，文本消息最多 2048
+	// @ 的手机号，@all 表示所有人
+	MentionedMobiles []string      `json:"mentionedMobiles" mapstructure:"mentionedMobiles"`
+	Interval         int           `json:"interval" mapstructure:"interval"` // 秒，每隔 interval 秒最多发送 limit 条，默认 3 秒
+	Limit            int           `json:"limit" mapstructure:"limit"`       // 默认 1 条
+	Senders          []WeComSender `json:"senders" mapstructure:"senders"`
+}
+
+type WeComSender struct {
+	WebhookURL string `json:"webhookUrl" mapstructure:"webhookUrl"`
+}
@@ -0,0 +1,166 @@
+// Package expr 过滤表达式，比如
+//
+//	level in ["ERROR", "FATAL"] && beat.hostname =~ "^prod-" && !(message contains "Broken pipe")
+//
+// 支持的运算符：
+//
+//	==，!=      字符串相等，区分大小写
+//	=~，!~      正则匹配，右边必须是字符串
+//	contains    左边为字符串则包含子串，为列表（比如 tags）则包含元素，元素比较忽略大小写
+//	in          左边的值在右边的列表中，忽略大小写
+//	&&，||，!   逻辑运算，可以用括号分组
+//
+// 字段为 log 中的字段路径，比如 level，beat.hostname，kubernetes.pod.name，不存在的字段为空字符串
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// Error 编译错误，Pos 为出错的位置，从 1 开始
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("col %d: %s", e.Pos, e.Msg)
+}
+
+// Expr 编译后的表达式
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile 编译表达式，编译时只检查语法，字段在求值时查找，不存在的字段为空字符串
+func Compile(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// String 表达式源码
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match log 是否满足表达式
+func (e *Expr) Match(logData logstash.LogData) bool {
+	return e.root.eval(logData)
+}
+
+type node interface {
+	eval(logData logstash.LogData) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(l logstash.LogData) bool { return n.left.eval(l) && n.right.eval(l) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(l logstash.LogData) bool { return n.left.eval(l) || n.right.eval(l) }
+
+type notNode struct{ n node }
+
+func (n notNode) eval(l logstash.LogData) bool { return !n.n.eval(l) }
+
+// operand 字段或者字面量
+type operand struct {
+	field string
+	value interface{} // string 或者 []string
+}
+
+func (o operand) get(l logstash.LogData) interface{} {
+	if o.field == "" {
+		return o.value
+	}
+
+	return l.Field(o.field)
+}
+
+type cmpNode struct {
+	op          string
+	left, right operand
+	re          *regexp.Regexp
+}
+
+func (n cmpNode) eval(l logstash.LogData) bool {
+	left, right := n.left.get(l), n.right.get(l)
+	switch n.op {
+	case "==":
+		return toString(left) == toString(right)
+	case "!=":
+		return toString(left) != toString(right)
+	case "=~", "!~":
+		matched := false
+		if list, ok := toList(left); ok {
+			for _, s := range list {
+				if n.re.MatchString(s) {
+					matched = true
+					break
+				}
+			}
+		} else {
+			matched = n.re.MatchString(toString(left))
+		}
+		return matched == (n.op == "=~")
+	case "contains":
+		if list, ok := toList(left); ok {
+			return contains(list, toString(right))
+		}
+		return strings.Contains(toString(left), toString(right))
+	case "in":
+		list, _ := toList(right)
+		return contains(list, toString(left))
+	}
+
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toString(v interface{}) string {
+	return logstash.String(v)
+}
+
+// toList 列表字段，Fields 中的列表为 []interface{}
+func toList(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		list := make([]string, len(v))
+		for i := range v {
+			list[i] = logstash.String(v[i])
+		}
+		return list, true
+	}
+
+	return nil, false
+}
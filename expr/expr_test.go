@@ -0,0 +1,73 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestMatch(t *testing.T) {
+	e, err := Compile(`level in ["ERROR", "FATAL"] && beat.hostname =~ "^prod-" && !(message contains "Broken pipe")`)
+	assert.Nil(t, err)
+
+	l := logstash.LogData{Level: "ERROR", Message: "NullPointerException", Beat: logstash.Beat{Hostname: "prod-1"}}
+	assert.True(t, e.Match(l))
+
+	l.Message = "java.io.IOException: Broken pipe"
+	assert.False(t, e.Match(l))
+
+	l.Message, l.Level = "", "INFO"
+	assert.False(t, e.Match(l))
+
+	e, err = Compile(`tags contains "mysql" || source == "/data/logs/a.log" || message =~ "\d+ms"`)
+	assert.Nil(t, err)
+	assert.True(t, e.Match(logstash.LogData{Tags: []string{"MYSQL"}}))
+	assert.True(t, e.Match(logstash.LogData{Source: "/data/logs/a.log"}))
+	assert.True(t, e.Match(logstash.LogData{Message: "cost 300ms"}))
+	assert.False(t, e.Match(logstash.LogData{Message: "say \"hi\""}))
+
+	e, err = Compile(`message contains "say \"hi\""`)
+	assert.Nil(t, err)
+	assert.True(t, e.Match(logstash.LogData{Message: "say \"hi\""}))
+}
+
+func TestMatchFields(t *testing.T) {
+	var l logstash.LogData
+	assert.Nil(t, json.Unmarshal([]byte(`{"level":"ERROR","offset":656674,"kubernetes":{"pod":{"name":"api-1"},"labels":["a","b"]}}`), &l))
+
+	for src, result := range map[string]bool{
+		`kubernetes.pod.name =~ "^api-"`:        true,
+		`offset == "656674"`:                    true,
+		`kubernetes.labels contains "B"`:        true,
+		`"a" in kubernetes.labels`:              true,
+		`trace_id == ""`:                        true,
+		`kubernetes.pod.name == "api-2"`:        false,
+		`level == "ERROR" && kubernetes == "x"`: false,
+	} {
+		e, err := Compile(src)
+		assert.Nil(t, err, src)
+		assert.Equal(t, e.Match(l), result, src)
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for src, pos := range map[string]int{
+		`level == `:                  10,
+		`level = "ERROR"`:            7,
+		`level == "ERROR`:            10,
+		`message =~ "("`:             12,
+		`level in "ERROR"`:           10,
+		`(level == "ERROR"`:          18,
+		`level == "ERROR" "FATAL"`:   18,
+		`level in ["ERROR" "FATAL"]`: 19,
+	} {
+		_, err := Compile(src)
+		e, ok := err.(*Error)
+		assert.True(t, ok, src)
+		if ok {
+			assert.Equal(t, e.Pos, pos, src)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokOp
+	tokPunct // ( ) [ ] ,
+)
+
+type token struct {
+	kind tokenKind
+	text string // tokString 为去掉引号和转义后的内容
+	pos  int
+}
+
+// 长的运算符在前面
+var operators = []string{"&&", "||", "==", "!=", "=~", "!~", "!"}
+
+func isIdent(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '@'
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()[],", r):
+			tokens = append(tokens, token{tokPunct, string(r), i + 1})
+			i++
+		case r == '"':
+			// 只转义 \" 和 \\，其他反斜杠原样保留，方便写正则
+			start := i
+			var sb strings.Builder
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, &Error{start + 1, "unterminated string"}
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				} else if runes[i] == '"' {
+					break
+				}
+				sb.WriteRune(runes[i])
+			}
+			tokens = append(tokens, token{tokString, sb.String(), start + 1})
+			i++
+		case isIdent(r):
+			start := i
+			for i < len(runes) && isIdent(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i]), start + 1})
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{tokOp, op, i + 1})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, &Error{i + 1, fmt.Sprintf("unexpected character %q", r)}
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, text: "end of expression", pos: len(runes) + 1}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(kind tokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if t := p.next(); t.kind != kind || t.text != text {
+		return &Error{t.pos, fmt.Sprintf("expected %q, got %q", text, t.text)}
+	}
+	return nil
+}
+
+// or := and { "||" and }
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(tokOp, "||") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+// and := unary { "&&" unary }
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(tokOp, "&&") {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+// unary := "!" unary | "(" or ")" | compare
+func (p *parser) unary() (node, error) {
+	if p.is(tokOp, "!") {
+		p.next()
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+
+	if p.is(tokPunct, "(") {
+		p.next()
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokPunct, ")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+
+	return p.compare()
+}
+
+// compare := operand op operand
+func (p *parser) compare() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.next()
+	n := cmpNode{op: t.text, left: left}
+	switch {
+	case t.kind == tokOp && (t.text == "==" || t.text == "!=" || t.text == "=~" || t.text == "!~"):
+	case t.kind == tokIdent && (t.text == "contains" || t.text == "in"):
+	default:
+		return nil, &Error{t.pos, fmt.Sprintf("expected operator, got %q", t.text)}
+	}
+
+	rightPos := p.peek().pos
+	if n.right, err = p.operand(); err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "=~", "!~":
+		s, ok := n.right.value.(string)
+		if !ok {
+			return nil, &Error{rightPos, n.op + " requires a string regex"}
+		}
+		if n.re, err = regexp.Compile(s); err != nil {
+			return nil, &Error{rightPos, err.Error()}
+		}
+	case "in":
+		if _, ok := n.right.value.([]string); !ok && n.right.field == "" {
+			return nil, &Error{rightPos, "in requires a list"}
+		}
+	}
+
+	return n, nil
+}
+
+// operand := field | string | "[" string { "," string } "]"
+func (p *parser) operand() (operand, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokString:
+		return operand{value: t.text}, nil
+	case t.kind == tokIdent:
+		return operand{field: t.text}, nil
+	case t.kind == tokPunct && t.text == "[":
+		list := []string{}
+		for !p.is(tokPunct, "]") {
+			if len(list) > 0 {
+				if err := p.expect(tokPunct, ","); err != nil {
+					return operand{}, err
+				}
+			}
+			s := p.next()
+			if s.kind != tokString {
+				return operand{}, &Error{s.pos, fmt.Sprintf("expected string in list, got %q", s.text)}
+			}
+			list = append(list, s.text)
+		}
+		p.next()
+		return operand{value: list}, nil
+	}
+
+	return operand{}, &Error{t.pos, fmt.Sprintf("expected field, string or list, got %q", t.text)}
+}
@@ -0,0 +1,106 @@
+package fingerprint
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/logstash"
+	"github.com/sdvdxl/logstash-http-push/stacktrace"
+)
+
+// 按顺序替换，先替换长的模式，避免被数字替换打散
+var normalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// 2017-02-10T16:21:28.942+0800，2017-02-10 16:21:28,942
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}([.,]\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<time>"},
+	// 线程名，比如 [http-nio-8080-exec-5]，[pool-1-thread-2]
+	{regexp.MustCompile(`\[[\w.\-]+-\d+\]`), "[<thread>]"},
+	{regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`), "<uuid>"},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b`), "<hex>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`), "<hex>"},
+	{regexp.MustCompile(`\d+(\.\d+){3}(:\d+)?`), "<ip>"},
+	{regexp.MustCompile(`\d+`), "<num>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// Normalize 去掉消息中每次都会变化的部分，比如时间，线程名，uuid，数字
+func Normalize(msg string) string {
+	for _, n := range normalizers {
+		msg = n.re.ReplaceAllString(msg, n.repl)
+	}
+
+	return strings.TrimSpace(msg)
+}
+
+// Of 根据日志文件和规范化后的消息生成指纹，有堆栈的用堆栈签名代替堆栈，
+// 行号变化和异常消息中的变量不影响指纹
+func Of(logData logstash.LogData) string {
+	h := sha1.New()
+	h.Write([]byte(logData.Source))
+	h.Write([]byte{0})
+	if t := stacktrace.Parse(logData.Message); t != nil {
+		h.Write([]byte(Normalize(t.Head)))
+		h.Write([]byte{0})
+		h.Write([]byte(t.Signature()))
+	} else {
+		h.Write([]byte(Normalize(logData.Message)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get 获取 log 的指纹，没有则计算
+func Get(logData logstash.LogData) string {
+	if logData.Fingerprint != "" {
+		return logData.Fingerprint
+	}
+
+	return Of(logData)
+}
+
+// Group 相同指纹的 log，LogData 为第一条
+type Group struct {
+	logstash.LogData
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Hosts     []string
+}
+
+// GroupBy 按指纹分组，按第一次出现的顺序返回
+func GroupBy(logs []logstash.LogData) []*Group {
+	groups := make([]*Group, 0, len(logs))
+	groupMap := make(map[string]*Group)
+	for _, logData := range logs {
+		fp := Get(logData)
+		g, exists := groupMap[fp]
+		if !exists {
+			logData.Fingerprint = fp
+			g = &Group{LogData: logData, FirstSeen: logData.Timestamp, LastSeen: logData.Timestamp}
+			groupMap[fp] = g
+			groups = append(groups, g)
+		}
+
+		g.Count++
+		if logData.Timestamp.Before(g.FirstSeen) {
+			g.FirstSeen = logData.Timestamp
+		}
+		if logData.Timestamp.After(g.LastSeen) {
+			g.LastSeen = logData.Timestamp
+		}
+		if host := logData.Beat.Hostname; host != "" {
+			if i := sort.SearchStrings(g.Hosts, host); i == len(g.Hosts) || g.Hosts[i] != host {
+				g.Hosts = append(g.Hosts, "")
+				copy(g.Hosts[i+1:], g.Hosts[i:])
+				g.Hosts[i] = host
+			}
+		}
+	}
+
+	return groups
+}
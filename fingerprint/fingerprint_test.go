@@ -0,0 +1,50 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestNormalize(t *testing.T) {
+	a := Normalize("2017-02-10T16:21:28.942+0800 ERROR user 123 not found, request 0f8fad5b-d9cb-469f-a165-70867728950e")
+	b := Normalize("2017-02-11T08:01:02.001+0800 ERROR user 456 not found, request 7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	assert.Equal(t, a, b)
+	assert.Equal(t, a, "<time> ERROR user <num> not found, request <uuid>")
+}
+
+func TestOf(t *testing.T) {
+	a := logstash.LogData{Source: "/data/logs/a.log", Message: "timeout after 3000ms"}
+	b := logstash.LogData{Source: "/data/logs/a.log", Message: "timeout after 5000ms"}
+	c := logstash.LogData{Source: "/data/logs/b.log", Message: "timeout after 3000ms"}
+	assert.Equal(t, Of(a), Of(b))
+	assert.True(t, Of(a) != Of(c))
+}
+
+func TestNormalizeThread(t *testing.T) {
+	a := Normalize("ERROR [http-nio-8080-exec-5] connection reset")
+	b := Normalize("ERROR [pool-2-thread-13] connection reset")
+	assert.Equal(t, a, b)
+}
+
+func TestGroupBy(t *testing.T) {
+	now := time.Now()
+	logs := []logstash.LogData{
+		{Source: "/data/logs/a.log", Message: "timeout after 3000ms", Timestamp: now, Beat: logstash.Beat{Hostname: "b"}},
+		{Source: "/data/logs/a.log", Message: "user not found"},
+		{Source: "/data/logs/a.log", Message: "timeout after 5000ms", Timestamp: now.Add(time.Second), Beat: logstash.Beat{Hostname: "a"}},
+		{Source: "/data/logs/a.log", Message: "timeout after 1000ms", Timestamp: now.Add(-time.Second), Beat: logstash.Beat{Hostname: "b"}},
+	}
+
+	groups := GroupBy(logs)
+	assert.Equal(t, len(groups), 2)
+	assert.Equal(t, groups[0].Count, 3)
+	assert.Equal(t, groups[0].Message, "timeout after 3000ms")
+	assert.Equal(t, groups[0].FirstSeen, now.Add(-time.Second))
+	assert.Equal(t, groups[0].LastSeen, now.Add(time.Second))
+	assert.Equal(t, strings.Join(groups[0].Hosts, ","), "a,b")
+	assert.Equal(t, groups[1].Count, 1)
+}
@@ -0,0 +1,224 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// esVersion 返回给客户端的 elasticsearch 版本，filebeat 会检查版本
+const esVersion = "7.17.0"
+
+// bulkItem 一个 action 和它的 source
+type bulkItem struct {
+	action  string
+	index   string
+	id      string
+	logData logstash.LogData
+	err     error
+}
+
+// bulkRoutes 注册 elasticsearch 兼容的接口，filebeat，fluent bit，vector 可以直接用 elasticsearch output 发送
+func bulkRoutes(engine *echo.Echo, handle Handler) {
+	info := func(c echo.Context) error {
+		c.Response().Header().Set("X-Elastic-Product", "Elasticsearch")
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"name":         "logstash-http-push",
+			"cluster_name": "logstash-http-push",
+			"version": map[string]interface{}{
+				"number":                              esVersion,
+				"build_flavor":                        "default",
+				"minimum_wire_compatibility_version":  "6.8.0",
+				"minimum_index_compatibility_version": "6.0.0-beta1",
+			},
+			"tagline": "You Know, for Search",
+		})
+	}
+	engine.GET("/", info)
+	engine.HEAD("/", info)
+
+	bulk := func(c echo.Context) error {
+		c.Response().Header().Set("X-Elastic-Product", "Elasticsearch")
+		start := time.Now()
+		body, err := decompress(c.Request())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, esError(err))
+		}
+		defer body.Close()
+
+		items, err := decodeBulk(body, c.Param("index"))
+		if err != nil {
+			log.Warn("bulk error: ", err)
+			return c.JSON(http.StatusBadRequest, esError(err))
+		}
+
+		hasErrors := false
+		results := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			result := map[string]interface{}{"_index": item.index, "_id": item.id, "_type": "_doc"}
+			if item.err != nil {
+				hasErrors = true
+				result["status"] = http.StatusBadRequest
+				result["error"] = map[string]interface{}{"type": "mapper_parsing_exception", "reason": item.err.Error()}
+			} else {
+				handle(item.logData)
+				result["status"] = http.StatusCreated
+				result["result"] = "created"
+				result["_version"] = 1
+			}
+			results = append(results, map[string]interface{}{item.action: result})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"took":   int64(time.Since(start) / time.Millisecond),
+			"errors": hasErrors,
+			"items":  results,
+		})
+	}
+	engine.POST("/_bulk", bulk)
+	engine.PUT("/_bulk", bulk)
+	engine.POST("/:index/_bulk", bulk)
+	engine.PUT("/:index/_bulk", bulk)
+}
+
+func esError(err error) map[string]interface{} {
+	return map[string]interface{}{
+		"error":  map[string]interface{}{"type": "illegal_argument_exception", "reason": err.Error()},
+		"status": http.StatusBadRequest,
+	}
+}
+
+// decodeBulk 解析 action 和 source 行，action 行错误时整个请求失败，和 elasticsearch 一致。
+// body 最大 maxBodySize，每行最大 maxValueSize
+func decodeBulk(r io.Reader, defaultIndex string) ([]bulkItem, error) {
+	reader := &lineReader{r: bufio.NewReaderSize(limitBody(r), 64<<10)}
+	var items []bulkItem
+	var cur *bulkItem
+	for line := 1; ; line++ {
+		data, err := reader.readLine()
+		if err == errLineTooLarge {
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cur.err = err
+			cur = nil
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if cur == nil {
+				item, err := bulkAction(data, defaultIndex)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+
+				items = append(items, item)
+				cur = &items[len(items)-1]
+				if cur.action == "delete" {
+					// delete 没有 source 行
+					cur.err = fmt.Errorf("action delete is not supported")
+					cur = nil
+				}
+			} else {
+				if cur.action == "update" {
+					cur.err = fmt.Errorf("action update is not supported")
+				} else if err := json.Unmarshal(data, &cur.logData); err != nil {
+					cur.err = err
+				} else {
+					normalize(&cur.logData)
+					cur.logData.Fields["_index"] = cur.index
+				}
+				cur = nil
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if cur != nil {
+		return nil, fmt.Errorf("missing source for the last action")
+	}
+
+	return items, nil
+}
+
+func bulkAction(data []byte, defaultIndex string) (bulkItem, error) {
+	var action map[string]struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id"`
+	}
+	if err := json.Unmarshal(data, &action); err != nil {
+		return bulkItem{}, fmt.Errorf("malformed action: %v", err)
+	}
+	if len(action) != 1 {
+		return bulkItem{}, fmt.Errorf("malformed action, expected one of index, create, update, delete")
+	}
+
+	for name, meta := range action {
+		switch name {
+		case "index", "create", "update", "delete":
+		default:
+			return bulkItem{}, fmt.Errorf("malformed action, unknown action %v", name)
+		}
+
+		item := bulkItem{action: name, index: meta.Index, id: meta.ID}
+		if item.index == "" {
+			item.index = defaultIndex
+		}
+		if item.id == "" {
+			id := make([]byte, 10)
+			rand.Read(id)
+			item.id = hex.EncodeToString(id)
+		}
+		return item, nil
+	}
+
+	return bulkItem{}, nil
+}
+
+// normalize 将 ECS（filebeat 7 以后）和 fluent bit 的字段映射到 LogData，时间为空时使用当前时间
+func normalize(l *logstash.LogData) {
+	if l.Fields == nil {
+		l.Fields = make(map[string]interface{})
+	}
+
+	fill := func(dst *string, paths ...string) {
+		for _, path := range paths {
+			if *dst != "" {
+				return
+			}
+			if v, ok := logstash.LookupMap(l.Fields, path); ok {
+				if s, ok := v.(string); ok {
+					*dst = s
+				}
+			}
+		}
+	}
+
+	fill(&l.Message, "log")
+	fill(&l.Level, "log.level", "severity")
+	fill(&l.Source, "log.file.path", "file")
+	fill(&l.InputType, "input.type")
+	fill(&l.Beat.Hostname, "host.name", "host.hostname", "agent.hostname", "host", "hostname")
+	fill(&l.Beat.Name, "agent.name")
+	fill(&l.Beat.Version, "agent.version")
+
+	if l.Timestamp.IsZero() {
+		l.Timestamp = time.Now()
+	}
+}
@@ -0,0 +1,95 @@
+package input
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestBulk(t *testing.T) {
+	var logs []logstash.LogData
+	engine := echo.New()
+	Routes(engine, noPush(t), func(logData logstash.LogData) {
+		logs = append(logs, logData)
+	})
+
+	body := `{"create":{"_index":"filebeat-7.17.0"}}
+{"@timestamp":"2017-02-10T08:21:28.942Z","message":"NullPointerException","log":{"level":"ERROR","file":{"path":"/data/logs/api.log"}},"host":{"name":"prod-1"},"agent":{"name":"prod-1","version":"7.17.0"},"input":{"type":"log"},"tags":["api"]}
+{"index":{}}
+{"log":"fluent bit line","level":"WARN","host":"node-1"}
+{"index":{"_id":"x"}}
+{"message":1}
+{"delete":{"_id":"y"}}
+`
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/logs/_bulk", strings.NewReader(body)))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	var resp struct {
+		Errors bool
+		Items  []map[string]struct {
+			Index  string `json:"_index"`
+			ID     string `json:"_id"`
+			Status int
+		}
+	}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Errors)
+	assert.Equal(t, len(resp.Items), 4)
+	assert.Equal(t, resp.Items[0]["create"].Index, "filebeat-7.17.0")
+	assert.Equal(t, resp.Items[0]["create"].Status, http.StatusCreated)
+	assert.Equal(t, resp.Items[1]["index"].Index, "logs")
+	assert.Equal(t, resp.Items[2]["index"].ID, "x")
+	assert.Equal(t, resp.Items[2]["index"].Status, http.StatusBadRequest)
+	assert.Equal(t, resp.Items[3]["delete"].Status, http.StatusBadRequest)
+
+	assert.Equal(t, len(logs), 2)
+	assert.True(t, logs[0].Timestamp.Equal(time.Date(2017, 2, 10, 8, 21, 28, 942000000, time.UTC)))
+	assert.Equal(t, logs[0].Level, "ERROR")
+	assert.Equal(t, logs[0].Source, "/data/logs/api.log")
+	assert.Equal(t, logs[0].Beat, logstash.Beat{Name: "prod-1", Version: "7.17.0", Hostname: "prod-1"})
+	assert.Equal(t, logs[0].InputType, "log")
+	assert.Equal(t, logs[0].Tags, []string{"api"})
+	assert.Equal(t, logs[0].Field("_index"), "filebeat-7.17.0")
+	assert.Equal(t, logs[1].Message, "fluent bit line")
+	assert.Equal(t, logs[1].Level, "WARN")
+	assert.Equal(t, logs[1].Beat.Hostname, "node-1")
+	assert.True(t, time.Since(logs[1].Timestamp) < time.Minute)
+
+	// source 行太长只有这一条失败
+	logs = nil
+	rec = httptest.NewRecorder()
+	body = "{\"index\":{}}\n" + strings.Repeat(" ", maxValueSize+1) + "\n{\"index\":{}}\n{\"message\":\"a\"}\n"
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader(body)))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	resp.Items = nil
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, resp.Items[0]["index"].Status, http.StatusBadRequest)
+	assert.Equal(t, resp.Items[1]["index"].Status, http.StatusCreated)
+	assert.Equal(t, len(logs), 1)
+
+	// body 太大整个请求失败
+	rec = httptest.NewRecorder()
+	body = strings.Repeat("{\"index\":{}}\n{\"message\":\""+strings.Repeat("x", 1<<20)+"\"}\n", maxBodySize>>20)
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader(body)))
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+	assert.True(t, strings.Contains(rec.Body.String(), "body too large"))
+	assert.Equal(t, len(logs), 1)
+
+	// action 错误整个请求失败
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader("{\"index\":{}}\n{}\n{\"upsert\":{}}\n{}\n")))
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("X-Elastic-Product"), "Elasticsearch")
+}
@@ -0,0 +1,343 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// 分块的 GELF 消息 2 个字节的标识，8 个字节的消息 id，1 个字节的序号，1 个字节的总块数
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+	// 超过这个时间没有收齐的分块消息丢弃
+	gelfChunkTimeout = 5 * time.Second
+	// 最多同时等待的分块消息数量和字节数
+	gelfMaxPending      = 1000
+	gelfMaxPendingBytes = 32 << 20
+)
+
+var (
+	// tcp 连接超过这个时间没有数据时关闭
+	gelfTCPIdleTimeout = 5 * time.Minute
+	// 最多同时处理的 tcp 连接数量，超过时新连接直接关闭
+	gelfMaxConns = 1000
+)
+
+// ListenGELF 监听 GELF 的 udp 和 tcp 端口，地址为空时不监听
+func ListenGELF(udpAddr, tcpAddr string, handle Handler) error {
+	if udpAddr != "" {
+		conn, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			return err
+		}
+		log.Info("gelf udp listen on ", udpAddr)
+		go serveGELFUDP(conn, handle)
+	}
+
+	if tcpAddr != "" {
+		l, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			return err
+		}
+		log.Info("gelf tcp listen on ", tcpAddr)
+		go serveGELFTCP(l, handle)
+	}
+
+	return nil
+}
+
+func serveGELFUDP(conn net.PacketConn, handle Handler) {
+	defer conn.Close()
+
+	g := newGELFChunks()
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Error("gelf udp stopped: ", err)
+			return
+		}
+
+		data, err := g.add(buf[:n], time.Now())
+		if err != nil {
+			log.Warn("gelf udp error: ", err)
+			continue
+		}
+		if data != nil {
+			gelfMessage(data, handle)
+		}
+	}
+}
+
+func serveGELFTCP(l net.Listener, handle Handler) {
+	defer l.Close()
+
+	conns := make(chan struct{}, gelfMaxConns)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			log.Error("gelf tcp stopped: ", err)
+			return
+		}
+
+		select {
+		case conns <- struct{}{}:
+		default:
+			log.Warn("gelf tcp too many connections, close ", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		go func() {
+			defer func() { <-conns }()
+			defer conn.Close()
+
+			scanner := bufio.NewScanner(idleReader{conn})
+			scanner.Buffer(make([]byte, 64<<10), maxValueSize)
+			scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+				if i := bytes.IndexByte(data, 0); i >= 0 {
+					return i + 1, data[:i], nil
+				}
+				if atEOF && len(data) > 0 {
+					return len(data), data, nil
+				}
+				return 0, nil, nil
+			})
+
+			for scanner.Scan() {
+				if data := bytes.TrimSpace(scanner.Bytes()); len(data) > 0 {
+					gelfMessage(data, handle)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				log.Warn("gelf tcp ", conn.RemoteAddr(), " error: ", err)
+			}
+		}()
+	}
+}
+
+// idleReader 每次读取前设置超时，连接空闲太久时读取失败
+type idleReader struct {
+	conn net.Conn
+}
+
+func (r idleReader) Read(p []byte) (int, error) {
+	if err := r.conn.SetReadDeadline(time.Now().Add(gelfTCPIdleTimeout)); err != nil {
+		return 0, err
+	}
+	return r.conn.Read(p)
+}
+
+// gelfMessage 解压并解析一条消息，失败时只记录日志
+func gelfMessage(data []byte, handle Handler) {
+	data, err := gelfDecompress(data)
+	if err == nil {
+		var logData logstash.LogData
+		if logData, err = gelfLogData(data); err == nil {
+			handle(logData)
+			return
+		}
+	}
+
+	log.Warn("gelf error: ", err)
+}
+
+// gelfChunks 组装分块的 udp 消息，只在一个 goroutine 中使用
+type gelfChunks struct {
+	pending   map[[8]byte]*gelfChunk
+	size      int // 所有等待中的分块的字节数
+	lastPurge time.Time
+}
+
+type gelfChunk struct {
+	parts    [][]byte
+	received int
+	size     int
+	first    time.Time
+}
+
+func newGELFChunks() *gelfChunks {
+	return &gelfChunks{pending: make(map[[8]byte]*gelfChunk)}
+}
+
+// add 处理一个 udp 包，不是分块的直接返回，分块的收齐后返回整个消息，没收齐返回 nil
+func (g *gelfChunks) add(packet []byte, now time.Time) ([]byte, error) {
+	if len(packet) < 2 || packet[0] != 0x1e || packet[1] != 0x0f {
+		return packet, nil
+	}
+
+	if now.Sub(g.lastPurge) >= time.Second {
+		for id, c := range g.pending {
+			if now.Sub(c.first) > gelfChunkTimeout {
+				g.remove(id)
+			}
+		}
+		g.lastPurge = now
+	}
+
+	if len(packet) < gelfChunkHeaderSize {
+		return nil, errors.New("chunk too short")
+	}
+	var id [8]byte
+	copy(id[:], packet[2:10])
+	seq, count := int(packet[10]), int(packet[11])
+	if count == 0 || count > gelfMaxChunks || seq >= count {
+		return nil, fmt.Errorf("invalid chunk %v of %v", seq, count)
+	}
+
+	c := g.pending[id]
+	if c == nil {
+		if len(g.pending) >= gelfMaxPending {
+			return nil, errors.New("too many pending chunked messages")
+		}
+		c = &gelfChunk{parts: make([][]byte, count), first: now}
+		g.pending[id] = c
+	} else if len(c.parts) != count {
+		g.remove(id)
+		return nil, fmt.Errorf("chunk count changed from %v to %v", len(c.parts), count)
+	}
+
+	if c.parts[seq] == nil {
+		part := packet[gelfChunkHeaderSize:]
+		if g.size+len(part) > gelfMaxPendingBytes {
+			if c.received == 0 {
+				delete(g.pending, id)
+			}
+			return nil, fmt.Errorf("pending chunks exceed %v bytes", gelfMaxPendingBytes)
+		}
+
+		// udp 的 buffer 会被复用，需要复制
+		c.parts[seq] = append([]byte{}, part...)
+		c.received++
+		c.size += len(part)
+		g.size += len(part)
+	}
+	if c.received < count {
+		return nil, nil
+	}
+
+	g.remove(id)
+	return bytes.Join(c.parts, nil), nil
+}
+
+func (g *gelfChunks) remove(id [8]byte) {
+	if c := g.pending[id]; c != nil {
+		g.size -= c.size
+		delete(g.pending, id)
+	}
+}
+
+// gelfDecompress 根据头部判断 gzip，zlib 或者不压缩
+func gelfDecompress(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err = ioutil.ReadAll(io.LimitReader(r, maxValueSize+1))
+	if err == nil && len(data) > maxValueSize {
+		err = fmt.Errorf("message too large, max %v bytes", maxValueSize)
+	}
+	return data, err
+}
+
+// gelfLogData full_message（没有时为 short_message）作为 message，level 为 syslog 的级别，
+// 附加字段去掉前缀 _ 保存为字段，_tag（docker 的 tag）作为 tag
+func gelfLogData(data []byte) (logstash.LogData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		return logstash.LogData{}, err
+	}
+
+	str := func(name string) string {
+		s, _ := m[name].(string)
+		return s
+	}
+
+	l := logstash.LogData{
+		Message:   str("full_message"),
+		InputType: "gelf",
+		Fields:    make(map[string]interface{}, len(m)),
+	}
+	if strings.TrimSpace(l.Message) == "" {
+		l.Message = str("short_message")
+	}
+	if l.Message == "" {
+		return l, errors.New("short_message is required")
+	}
+
+	for k, v := range m {
+		if len(k) > 1 && k[0] == '_' {
+			k = k[1:]
+		}
+		l.Fields[k] = v
+	}
+
+	l.Beat.Hostname = str("host")
+	if level, ok := m["level"].(json.Number); ok {
+		if n, err := level.Int64(); err == nil {
+			l.Level = syslogLevel(n)
+		}
+	}
+	if ts, ok := m["timestamp"].(json.Number); ok {
+		if f, err := ts.Float64(); err == nil && f > 0 {
+			l.Timestamp = time.Unix(0, int64(f*1e9)).Round(time.Microsecond)
+		}
+	}
+	if tag := str("_tag"); tag != "" {
+		l.Tags = []string{tag}
+	}
+	// gelf 1.0 的 file
+	if l.Source = str("_file"); l.Source == "" {
+		l.Source = str("file")
+	}
+
+	normalize(&l)
+	return l, nil
+}
+
+// syslogLevel syslog 的级别，0-2 FATAL，3 ERROR，4 WARN，5-6 INFO，7 DEBUG
+func syslogLevel(n int64) string {
+	switch {
+	case n < 0 || n > 7:
+		return ""
+	case n <= 2:
+		return "FATAL"
+	case n == 3:
+		return "ERROR"
+	case n == 4:
+		return "WARN"
+	case n <= 6:
+		return "INFO"
+	}
+	return "DEBUG"
+}
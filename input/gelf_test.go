@@ -0,0 +1,207 @@
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const gelfSample = `{"version":"1.1","host":"docker-1","short_message":"panic: boom","full_message":"panic: boom\n\ngoroutine 1 [running]:","timestamp":1385053862.3072,"level":3,"_container_name":"api","_tag":"billing","_user_id":9001}`
+
+func TestGELFLogData(t *testing.T) {
+	l, err := gelfLogData([]byte(gelfSample))
+	assert.Nil(t, err)
+	assert.Equal(t, l.Message, "panic: boom\n\ngoroutine 1 [running]:")
+	assert.Equal(t, l.Level, "ERROR")
+	assert.Equal(t, l.Beat.Hostname, "docker-1")
+	assert.Equal(t, l.InputType, "gelf")
+	assert.Equal(t, l.Tags, []string{"billing"})
+	assert.Equal(t, l.Field("container_name"), "api")
+	assert.Equal(t, l.Field("user_id"), json.Number("9001"))
+	assert.Equal(t, l.Field("short_message"), "panic: boom")
+	assert.True(t, l.Timestamp.Equal(time.Unix(1385053862, 307200000)))
+
+	l, err = gelfLogData([]byte(`{"short_message":"only short","level":6}`))
+	assert.Nil(t, err)
+	assert.Equal(t, l.Message, "only short")
+	assert.Equal(t, l.Level, "INFO")
+	assert.False(t, l.Timestamp.IsZero())
+
+	_, err = gelfLogData([]byte(`{"host":"x"}`))
+	assert.NotNil(t, err)
+	_, err = gelfLogData([]byte(`not json`))
+	assert.NotNil(t, err)
+}
+
+func TestGELFChunks(t *testing.T) {
+	chunk := func(id byte, seq, count int, data string) []byte {
+		return append([]byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count)}, data...)
+	}
+
+	g := newGELFChunks()
+	now := time.Now()
+
+	// 不分块
+	data, err := g.add([]byte(gelfSample), now)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), gelfSample)
+
+	// 乱序，重复的块
+	data, err = g.add(chunk(1, 2, 3, "c"), now)
+	assert.Nil(t, err)
+	assert.Nil(t, data)
+	data, _ = g.add(chunk(1, 0, 3, "a"), now)
+	assert.Nil(t, data)
+	data, _ = g.add(chunk(1, 0, 3, "x"), now)
+	assert.Nil(t, data)
+	data, err = g.add(chunk(1, 1, 3, "b"), now)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), "abc")
+	assert.Equal(t, len(g.pending), 0)
+
+	// 超时丢弃
+	g.add(chunk(2, 0, 2, "a"), now)
+	data, _ = g.add(chunk(2, 1, 2, "b"), now.Add(gelfChunkTimeout+time.Second))
+	assert.Nil(t, data)
+
+	_, err = g.add(chunk(3, 3, 3, "a"), now)
+	assert.NotNil(t, err)
+
+	// 等待中的分块超过字节数限制
+	g = newGELFChunks()
+	part := string(make([]byte, 60000))
+	err = nil
+	for i := 0; err == nil; i++ {
+		_, err = g.add(chunk(byte(i/100), i%100, 101, part), now)
+	}
+	assert.True(t, g.size <= gelfMaxPendingBytes)
+	data, err = g.add(chunk(0, 100, 101, "a"), now)
+	assert.Nil(t, err)
+	assert.Equal(t, len(data), 100*60000+1)
+	g.add(chunk(0, 0, 1, "a"), now.Add(gelfChunkTimeout+time.Second))
+	assert.Equal(t, len(g.pending), 0)
+	assert.Equal(t, g.size, 0)
+
+	_, err = g.add(chunk(3, 3, 3, "a"), now)
+	assert.NotNil(t, err)
+	_, err = g.add(chunk(3, 0, gelfMaxChunks+1, "a"), now)
+	assert.NotNil(t, err)
+	_, err = g.add([]byte{0x1e, 0x0f, 1}, now)
+	assert.NotNil(t, err)
+}
+
+func TestGELFDecompress(t *testing.T) {
+	var gz, zl bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(gelfSample))
+	w.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(gelfSample))
+	zw.Close()
+
+	for _, data := range [][]byte{gz.Bytes(), zl.Bytes(), []byte(gelfSample)} {
+		data, err := gelfDecompress(data)
+		assert.Nil(t, err)
+		assert.Equal(t, string(data), gelfSample)
+	}
+}
+
+func TestGELFListen(t *testing.T) {
+	logs := make(chan logstash.LogData, 10)
+	handle := func(logData logstash.LogData) { logs <- logData }
+
+	receive := func() string {
+		select {
+		case l := <-logs:
+			return l.Message
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go serveGELFTCP(l, handle)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	conn.Write([]byte(`{"short_message":"a","timestamp":1385053862.3072}` + "\x00" + `{"short_message":"b"}` + "\x00\n" + `{"short_message":"c"}`))
+	conn.Close()
+	select {
+	case l := <-logs:
+		assert.Equal(t, l.Message, "a")
+		// 时间不做时区修正
+		assert.True(t, l.Timestamp.Equal(time.Unix(1385053862, 307200000)))
+	case <-time.After(5 * time.Second):
+		t.Error("timeout")
+	}
+	assert.Equal(t, receive(), "b")
+	assert.Equal(t, receive(), "c")
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go serveGELFUDP(pc, handle)
+	defer pc.Close()
+
+	var zl bytes.Buffer
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(`{"short_message":"udp"}`))
+	zw.Close()
+	body := zl.Bytes()
+
+	uc, err := net.Dial("udp", pc.LocalAddr().String())
+	assert.Nil(t, err)
+	defer uc.Close()
+	half := len(body) / 2
+	uc.Write(append([]byte{0x1e, 0x0f, 9, 9, 9, 9, 9, 9, 9, 9, 1, 2}, body[half:]...))
+	uc.Write(append([]byte{0x1e, 0x0f, 9, 9, 9, 9, 9, 9, 9, 9, 0, 2}, body[:half]...))
+	assert.Equal(t, receive(), "udp")
+}
+
+func TestGELFTCPLimit(t *testing.T) {
+	timeout, maxConns := gelfTCPIdleTimeout, gelfMaxConns
+	gelfTCPIdleTimeout, gelfMaxConns = 200*time.Millisecond, 1
+	defer func() { gelfTCPIdleTimeout, gelfMaxConns = timeout, maxConns }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go serveGELFTCP(l, func(logData logstash.LogData) {})
+	defer l.Close()
+
+	closed := func(conn net.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		return err == io.EOF
+	}
+
+	conn1, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn1.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// 超过连接数限制直接关闭
+	conn2, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn2.Close()
+	assert.True(t, closed(conn2))
+
+	// 空闲超时关闭
+	start := time.Now()
+	assert.True(t, closed(conn1))
+	assert.True(t, time.Since(start) < 2*time.Second)
+
+	conn3, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn3.Close()
+	conn3.Write([]byte(`{"short_message":"a"}` + "\x00"))
+	assert.True(t, closed(conn3))
+}
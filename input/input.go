@@ -0,0 +1,145 @@
+// Package input 接收 log 的 http 接口
+package input
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// 一次 push 请求解压后最大的字节数
+const maxBodySize = 64 << 20
+
+var errBodyTooLarge = fmt.Errorf("body too large, max %v bytes", maxBodySize)
+
+// Handler 处理解析出来的 log
+type Handler func(logData logstash.LogData)
+
+// LineError 某一行解析失败，Index 为数组中的位置，从 0 开始，不是数组时为空
+type LineError struct {
+	Line  int    `json:"line"`
+	Index *int   `json:"index,omitempty"`
+	Error string `json:"error"`
+}
+
+// Result 解析结果
+type Result struct {
+	Accepted int         `json:"accepted"`
+	Errors   []LineError `json:"errors,omitempty"`
+}
+
+// Routes 注册接收 log 的 http 接口
+//
+//	POST /push                logstash http output，支持 json，json_batch，json_lines，可以 gzip 或者 deflate 压缩
+//	POST /_bulk，/:index/_bulk  elasticsearch bulk
+//	POST /loki/api/v1/push     loki push，支持 json 和 snappy 压缩的 protobuf
+//	POST /v1/logs              OTLP/HTTP logs，支持 protobuf 和 json
+//
+// push 处理 /push 的 log，logstash 的时间可能需要按时区修正，handle 处理其他接口的 log，时间都是准确的
+func Routes(engine *echo.Echo, push, handle Handler) {
+	bulkRoutes(engine, handle)
+	lokiRoutes(engine, handle)
+	otlpRoutes(engine, handle)
+
+	engine.POST("/push", func(c echo.Context) error {
+		body, err := decompress(c.Request())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		defer body.Close()
+
+		return respond(c, DecodeJSON(body, push))
+	})
+}
+
+// respond 全部失败返回 400，否则返回 200 和失败的行
+func respond(c echo.Context, result Result) error {
+	if len(result.Errors) > 0 {
+		log.Warn("push ", len(result.Errors), " errors, first: ", result.Errors[0].Error)
+		if result.Accepted == 0 {
+			return c.JSON(http.StatusBadRequest, result)
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// decompress 根据 Content-Encoding 解压 body
+func decompress(req *http.Request) (io.ReadCloser, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return req.Body, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(req.Body)
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+		return readCloser{r, req.Body}, nil
+	case "deflate":
+		// http 的 deflate 应该是 zlib 格式，但也有客户端发送不带 zlib 头的原始 deflate
+		br := bufio.NewReader(req.Body)
+		if header, err := br.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			r, err := zlib.NewReader(br)
+			if err != nil {
+				req.Body.Close()
+				return nil, err
+			}
+			return readCloser{r, req.Body}, nil
+		}
+		return readCloser{flate.NewReader(br), req.Body}, nil
+	default:
+		req.Body.Close()
+		return nil, fmt.Errorf("unsupported Content-Encoding: %v", encoding)
+	}
+}
+
+// readCloser 关闭时同时关闭解压的 reader 和原始的 body
+type readCloser struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (r readCloser) Close() error {
+	r.ReadCloser.Close()
+	return r.body.Close()
+}
+
+// bodyLimiter 读取超过 maxBodySize 时返回 errBodyTooLarge，而不是像 io.LimitReader 一样截断
+type bodyLimiter struct {
+	r io.Reader
+	n int64
+}
+
+func limitBody(r io.Reader) io.Reader {
+	return &bodyLimiter{r: r, n: maxBodySize}
+}
+
+func (l *bodyLimiter) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// 刚好 maxBodySize 时不算超过
+		var b [1]byte
+		if n, err := l.r.Read(b[:]); n > 0 {
+			return 0, errBodyTooLarge
+		} else if err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
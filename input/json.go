@@ -0,0 +1,210 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// 一个 json 最大的字节数
+const maxValueSize = 16 << 20
+
+var errLineTooLarge = fmt.Errorf("json too large, max %v bytes", maxValueSize)
+
+// DecodeJSON 逐行解析 json，每行可以是一个或者多个对象或者对象数组（json_lines），
+// 没有在一行结束的 json（json，json_batch，格式化过的 json）用 json.Decoder 继续读取后面的行。
+// 每解析出一条 log 就调用 handle，某一行失败不影响其他行
+func DecodeJSON(r io.Reader, handle Handler) Result {
+	var result Result
+	reader := &lineReader{r: bufio.NewReaderSize(limitBody(r), 64<<10)}
+
+	line := 0
+	// 上一个多行 json 结束的那一行剩下的部分，不算新的一行
+	tail := false
+	for {
+		data, err := reader.readLine()
+		if len(data) > 0 || err == errLineTooLarge {
+			if !tail {
+				line++
+			}
+			tail = false
+		}
+		if err == errLineTooLarge {
+			result.Errors = append(result.Errors, LineError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+			br := bytes.NewReader(rest)
+			decoder := json.NewDecoder(br)
+			var raw json.RawMessage
+			decodeErr := decoder.Decode(&raw)
+			if decodeErr == nil {
+				decodeValue(raw, line, handle, &result)
+				buffered, _ := ioutil.ReadAll(decoder.Buffered())
+				rest = rest[len(rest)-br.Len()-len(buffered):]
+				continue
+			}
+
+			if decodeErr == io.ErrUnexpectedEOF {
+				// 这一行没有结束，从这个 json 的开头继续读取后面的行
+				start := line
+				raw, newlines, decodeErr := reader.decodeValue(rest)
+				line += newlines
+				tail = true
+				if decodeErr != nil {
+					result.Errors = append(result.Errors, LineError{Line: start, Error: decodeErr.Error()})
+				} else {
+					decodeValue(raw, start, handle, &result)
+				}
+				break
+			}
+
+			result.Errors = append(result.Errors, LineError{Line: line, Error: decodeErr.Error()})
+			break
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, LineError{Line: line + 1, Error: err.Error()})
+			return result
+		}
+	}
+
+	return result
+}
+
+// decodeValue 解析一个 json 对象或者数组
+func decodeValue(value []byte, line int, handle Handler, result *Result) {
+	if value[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil {
+			result.Errors = append(result.Errors, LineError{Line: line, Error: err.Error()})
+			return
+		}
+
+		for i := range items {
+			var logData logstash.LogData
+			if err := json.Unmarshal(items[i], &logData); err != nil {
+				index := i
+				result.Errors = append(result.Errors, LineError{Line: line, Index: &index, Error: err.Error()})
+				continue
+			}
+
+			result.Accepted++
+			handle(logData)
+		}
+		return
+	}
+
+	var logData logstash.LogData
+	if err := json.Unmarshal(value, &logData); err != nil {
+		result.Errors = append(result.Errors, LineError{Line: line, Error: err.Error()})
+		return
+	}
+
+	result.Accepted++
+	handle(logData)
+}
+
+// lineReader 按行读取，多行 json 用 json.Decoder 流式解析，
+// decoder 多读的部分放在 pending 中，下次读取时先返回
+type lineReader struct {
+	pending []byte
+	r       *bufio.Reader
+}
+
+// readLine 读取一行，包括结尾的换行符，超过 maxValueSize 时丢弃这一行，返回 errLineTooLarge
+func (lr *lineReader) readLine() ([]byte, error) {
+	var line []byte
+	if len(lr.pending) > 0 {
+		if i := bytes.IndexByte(lr.pending, '\n'); i >= 0 {
+			line = lr.pending[:i+1]
+			lr.pending = lr.pending[i+1:]
+			return line, nil
+		}
+		line, lr.pending = lr.pending, nil
+	}
+
+	for {
+		frag, err := lr.r.ReadSlice('\n')
+		if len(line)+len(frag) > maxValueSize {
+			for err == bufio.ErrBufferFull {
+				_, err = lr.r.ReadSlice('\n')
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			return nil, errLineTooLarge
+		}
+
+		line = append(line, frag...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// decodeValue 从 first 开始解析一个完整的 json，返回 json 中的换行数
+func (lr *lineReader) decodeValue(first []byte) (json.RawMessage, int, error) {
+	pending := bytes.NewReader(lr.pending)
+	limited := &io.LimitedReader{R: io.MultiReader(bytes.NewReader(first), pending, lr.r), N: maxValueSize}
+	counter := &newlineCounter{}
+	decoder := json.NewDecoder(io.TeeReader(limited, counter))
+
+	var raw json.RawMessage
+	err := decoder.Decode(&raw)
+
+	// decoder 读取了但没有用到的部分放回去，出错时 buffered 从 json 的开头开始
+	buffered, _ := ioutil.ReadAll(decoder.Buffered())
+	switch e := err.(type) {
+	case nil:
+	case *json.SyntaxError:
+		end := int(e.Offset) - 1
+		if end < 0 {
+			end = 0
+		} else if end > len(buffered) {
+			end = len(buffered)
+		}
+		if i := bytes.LastIndexByte(buffered[:end], '\n'); i >= 0 && end < len(buffered) && (buffered[end] == '{' || buffered[end] == '[') &&
+			len(bytes.TrimSpace(buffered[i:end])) == 0 {
+			// 新的一行开始了另一个 json，前面的 json 被截断了（比如 json_lines 中的一行），从这一行继续
+			buffered = buffered[i:]
+		} else if i := bytes.IndexByte(buffered[end:], '\n'); i >= 0 {
+			// 丢弃到出错的那一行结束，换行符留给下次读取
+			buffered = buffered[end+i:]
+		} else {
+			buffered = nil
+		}
+	default:
+		buffered = nil
+	}
+	rest, _ := ioutil.ReadAll(pending)
+	lr.pending = append(buffered, rest...)
+	newlines := counter.n - bytes.Count(buffered, []byte{'\n'})
+
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		if limited.N <= 0 {
+			return nil, newlines, fmt.Errorf("json too large, max %v bytes", maxValueSize)
+		}
+		return nil, newlines, fmt.Errorf("unexpected end of JSON input")
+	}
+	return raw, newlines, err
+}
+
+// newlineCounter 统计写入的换行数
+type newlineCounter struct {
+	n int
+}
+
+func (c *newlineCounter) Write(p []byte) (int, error) {
+	c.n += bytes.Count(p, []byte{'\n'})
+	return len(p), nil
+}
@@ -0,0 +1,151 @@
+package input
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// noPush /push 以外的接口不应该使用 push，push 的时间会按时区修正
+func noPush(t *testing.T) Handler {
+	return func(logData logstash.LogData) {
+		t.Error("only /push should use the push handler")
+	}
+}
+
+func decode(body string) (Result, []string) {
+	var messages []string
+	result := DecodeJSON(strings.NewReader(body), func(logData logstash.LogData) {
+		messages = append(messages, logData.Message)
+	})
+	return result, messages
+}
+
+func TestDecodeJSON(t *testing.T) {
+	// json
+	result, messages := decode(`{"message":"a","level":"ERROR"}`)
+	assert.Equal(t, result, Result{Accepted: 1})
+	assert.Equal(t, messages, []string{"a"})
+
+	// json_batch
+	result, messages = decode(`[{"message":"a"},{"message":"b"}]`)
+	assert.Equal(t, result, Result{Accepted: 2})
+	assert.Equal(t, messages, []string{"a", "b"})
+
+	// json_lines，错误的行不影响其他行
+	result, messages = decode("{\"message\":\"a\"}\n\n{\"message\":\"b\"\n{\"message\":\"c\"}\r\n[{\"message\":\"d\"},{\"tags\":\"x\"}]\n")
+	assert.Equal(t, messages, []string{"a", "c", "d"})
+	assert.Equal(t, result.Accepted, 3)
+	assert.Equal(t, len(result.Errors), 2)
+	assert.Equal(t, result.Errors[0].Line, 3)
+	assert.Equal(t, result.Errors[1].Line, 5)
+	assert.Equal(t, *result.Errors[1].Index, 1)
+
+	// 格式化过的 json
+	result, messages = decode("{\n  \"message\": \"a\"\n}\n[\n  {\"message\": \"b\"}\n]\n{\n  \"message\": \"c\"\n")
+	assert.Equal(t, messages, []string{"a", "b"})
+	assert.Equal(t, result.Errors, []LineError{{Line: 7, Error: "unexpected end of JSON input"}})
+
+	// 格式化过的 json 中间出错，从出错的下一行继续；结束的那一行剩下的部分继续解析
+	result, messages = decode("{\n  \"message\": \"a\",\n  x\n}\n{\n  \"message\": \"b\"\n} {\"message\": \"c\"}\n{\"message\":")
+	assert.Equal(t, messages, []string{"b", "c"})
+	assert.Equal(t, len(result.Errors), 3)
+	assert.Equal(t, result.Errors[0].Line, 1)
+	assert.Equal(t, result.Errors[1].Line, 4)
+	assert.Equal(t, result.Errors[2].Line, 8)
+
+	// 没有在第一行结束的 json，一行中多个 json
+	result, messages = decode("{\"level\":\"ERROR\",\n\"message\":\"a\"}\n[{\"message\":\"b\"},\n{\"message\":\"c\"}]\n{\"message\":\"d\"}{\"message\":\"e\"} [{\"message\":\"f\"}]\n")
+	assert.Equal(t, result, Result{Accepted: 6})
+	assert.Equal(t, messages, []string{"a", "b", "c", "d", "e", "f"})
+
+	// 截断的行后面是新的 json
+	result, messages = decode("{\"message\":\"a\",\n{\"message\":\"b\"}\n")
+	assert.Equal(t, messages, []string{"b"})
+	assert.Equal(t, len(result.Errors), 1)
+	assert.Equal(t, result.Errors[0].Line, 1)
+
+	// 超过最大长度的行，包括没有换行符的 body
+	result, messages = decode(strings.Repeat("x", maxValueSize+1) + "\n{\"message\":\"a\"}\n" + strings.Repeat(" ", maxValueSize+1))
+	assert.Equal(t, messages, []string{"a"})
+	assert.Equal(t, result.Errors, []LineError{{Line: 1, Error: errLineTooLarge.Error()}, {Line: 3, Error: errLineTooLarge.Error()}})
+
+	// 很多行的 json 流式解析，行号正确
+	body := "[\n" + strings.Repeat("  {\"message\": \"a\"},\n", 100000) + "  {\"message\": \"b\"}\n]\nx\n"
+	result, messages = decode(body)
+	assert.Equal(t, result.Accepted, 100001)
+	assert.Equal(t, messages[100000], "b")
+	assert.Equal(t, result.Errors[0].Line, 100004)
+}
+
+func TestPushEncoding(t *testing.T) {
+	var messages []string
+	engine := echo.New()
+	Routes(engine, func(logData logstash.LogData) {
+		messages = append(messages, logData.Message)
+	}, func(logData logstash.LogData) {
+		t.Error("/push should use the push handler")
+	})
+
+	body := "{\"message\":\"a\"}\n{\"message\":\"b\"}\n"
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw":     func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw },
+	}
+	for encoding, newWriter := range compress {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		w.Write([]byte(body))
+		w.Close()
+
+		messages = nil
+		req := httptest.NewRequest(http.MethodPost, "/push", &buf)
+		req.Header.Set("Content-Encoding", strings.Replace(encoding, "raw", "deflate", 1))
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+
+		assert.Equal(t, rec.Code, http.StatusOK, encoding)
+		assert.Equal(t, messages, []string{"a", "b"}, encoding)
+	}
+
+	// 全部失败返回 400
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/push", strings.NewReader("not json")))
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	var result Result
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, result.Errors[0].Line, 1)
+
+	// 解压后超过最大长度
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	for i := 0; i < maxBodySize/maxValueSize+1; i++ {
+		w.Write([]byte(strings.Repeat("\n", maxValueSize)))
+	}
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/push", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+	assert.True(t, strings.Contains(rec.Body.String(), "body too large"))
+
+	req = httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(body))
+	req.Header.Set("Content-Encoding", "br")
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+}
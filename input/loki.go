@@ -0,0 +1,330 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// lokiStream 一个 stream 的 label 和它的所有行
+type lokiStream struct {
+	labels  map[string]string
+	entries []lokiEntry
+}
+
+type lokiEntry struct {
+	timestamp time.Time
+	line      string
+	// structured metadata，loki 2.9 以后
+	metadata map[string]string
+}
+
+// lokiRoutes 注册 loki 的 push 接口，promtail，grafana agent 可以直接把 loki 的地址指向这里
+func lokiRoutes(engine *echo.Echo, handle Handler) {
+	engine.POST("/loki/api/v1/push", func(c echo.Context) error {
+		body, err := decompress(c.Request())
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		defer body.Close()
+
+		streams, err := decodeLoki(body, c.Request().Header.Get("Content-Type"))
+		if err != nil {
+			// 和 loki 一样，解析失败时整个请求都不接收
+			log.Warn("loki push error: ", err)
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		for _, stream := range streams {
+			for _, entry := range stream.entries {
+				handle(lokiLogData(stream.labels, entry))
+			}
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+// decodeLoki 根据 Content-Type 解析 json 或者 snappy 压缩的 protobuf，默认是 protobuf
+func decodeLoki(r io.Reader, contentType string) ([]lokiStream, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBodySize {
+		return nil, fmt.Errorf("body too large, max %v bytes", maxBodySize)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" {
+		return decodeLokiJSON(data)
+	}
+
+	if n, err := snappy.DecodedLen(data); err != nil {
+		return nil, err
+	} else if n > maxBodySize {
+		return nil, fmt.Errorf("body too large, max %v bytes", maxBodySize)
+	}
+	if data, err = snappy.Decode(nil, data); err != nil {
+		return nil, err
+	}
+	return decodeLokiProto(data)
+}
+
+// decodeLokiJSON {"streams":[{"stream":{"job":"app"},"values":[["纳秒时间戳","行",{"trace_id":"..."}]]}]}
+func decodeLokiJSON(data []byte) ([]lokiStream, error) {
+	var req struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	streams := make([]lokiStream, 0, len(req.Streams))
+	for i, s := range req.Streams {
+		stream := lokiStream{labels: s.Stream}
+		for j, value := range s.Values {
+			var entry lokiEntry
+			if err := decodeLokiValue(value, &entry); err != nil {
+				return nil, fmt.Errorf("streams[%v].values[%v]: %v", i, j, err)
+			}
+			stream.entries = append(stream.entries, entry)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, nil
+}
+
+func decodeLokiValue(value []json.RawMessage, entry *lokiEntry) error {
+	if len(value) != 2 && len(value) != 3 {
+		return fmt.Errorf("expected [timestamp, line] or [timestamp, line, metadata], got %v elements", len(value))
+	}
+
+	var ts string
+	if err := json.Unmarshal(value[0], &ts); err != nil {
+		return fmt.Errorf("timestamp: %v", err)
+	}
+	ns, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("timestamp: %v", err)
+	}
+	entry.timestamp = time.Unix(0, ns)
+
+	if err := json.Unmarshal(value[1], &entry.line); err != nil {
+		return fmt.Errorf("line: %v", err)
+	}
+	if len(value) == 3 {
+		if err := json.Unmarshal(value[2], &entry.metadata); err != nil {
+			return fmt.Errorf("metadata: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// decodeLokiProto 解析 logproto.PushRequest
+//
+//	PushRequest  { repeated StreamAdapter streams = 1; }
+//	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter { Timestamp timestamp = 1; string line = 2; repeated LabelPairAdapter structuredMetadata = 3; }
+func decodeLokiProto(data []byte) ([]lokiStream, error) {
+	var streams []lokiStream
+	err := eachField(data, func(f protoField) error {
+		if f.num != 1 || f.wire != wireBytes {
+			return nil
+		}
+
+		var stream lokiStream
+		err := eachField(f.bytes, func(f protoField) error {
+			if f.wire != wireBytes {
+				return nil
+			}
+
+			switch f.num {
+			case 1:
+				labels, err := parseLabels(string(f.bytes))
+				stream.labels = labels
+				return err
+			case 2:
+				entry, err := decodeLokiEntry(f.bytes)
+				stream.entries = append(stream.entries, entry)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("streams[%v]: %v", len(streams), err)
+		}
+
+		streams = append(streams, stream)
+		return nil
+	})
+
+	return streams, err
+}
+
+func decodeLokiEntry(data []byte) (lokiEntry, error) {
+	var entry lokiEntry
+	err := eachField(data, func(f protoField) error {
+		if f.wire != wireBytes {
+			return nil
+		}
+
+		switch f.num {
+		case 1:
+			// google.protobuf.Timestamp { int64 seconds = 1; int32 nanos = 2; }
+			var sec, nsec int64
+			err := eachField(f.bytes, func(f protoField) error {
+				switch {
+				case f.num == 1 && f.wire == wireVarint:
+					sec = int64(f.value)
+				case f.num == 2 && f.wire == wireVarint:
+					nsec = int64(int32(f.value))
+				}
+				return nil
+			})
+			entry.timestamp = time.Unix(sec, nsec)
+			return err
+		case 2:
+			entry.line = string(f.bytes)
+		case 3:
+			// LabelPairAdapter { string name = 1; string value = 2; }
+			var name, value string
+			err := eachField(f.bytes, func(f protoField) error {
+				switch {
+				case f.num == 1 && f.wire == wireBytes:
+					name = string(f.bytes)
+				case f.num == 2 && f.wire == wireBytes:
+					value = string(f.bytes)
+				}
+				return nil
+			})
+			if entry.metadata == nil {
+				entry.metadata = make(map[string]string)
+			}
+			entry.metadata[name] = value
+			return err
+		}
+		return nil
+	})
+
+	return entry, err
+}
+
+// eachField 依次处理 message 的每个字段
+func eachField(data []byte, fn func(f protoField) error) error {
+	r := protoReader{data: data}
+	for {
+		f, ok, err := r.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+}
+
+// parseLabels 解析 prometheus 格式的 label，比如 {job="varlogs", filename="/var/log/syslog"}
+func parseLabels(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid labels %q", s)
+	}
+
+	labels := make(map[string]string)
+	rest := strings.TrimSpace(s[1 : len(s)-1])
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid labels %q", s)
+		}
+		name := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimSpace(rest[eq+1:])
+
+		// 找到没有转义的结束引号
+		end := -1
+		if strings.HasPrefix(rest, `"`) {
+			for i := 1; i < len(rest); i++ {
+				if rest[i] == '\\' {
+					i++
+				} else if rest[i] == '"' {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("invalid labels %q", s)
+		}
+		value, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid labels %q: %v", s, err)
+		}
+		labels[name] = value
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if rest != "" {
+			if rest[0] != ',' {
+				return nil, fmt.Errorf("invalid labels %q", s)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+
+	return labels, nil
+}
+
+// lokiLogData label 和 structured metadata 放到 Fields，label 的值作为 tag，
+// level，filename，host 等 label 映射到对应的字段
+func lokiLogData(labels map[string]string, entry lokiEntry) logstash.LogData {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	l := logstash.LogData{
+		Message:   entry.line,
+		Timestamp: entry.timestamp,
+		InputType: "loki",
+		Fields:    make(map[string]interface{}, len(labels)+len(entry.metadata)),
+	}
+	for k, v := range entry.metadata {
+		l.Fields[k] = v
+	}
+	for _, name := range names {
+		l.Fields[name] = labels[name]
+		l.Tags = append(l.Tags, labels[name])
+	}
+
+	l.Level = firstLabel(labels, "level", "detected_level")
+	l.Source = firstLabel(labels, "filename")
+	normalize(&l)
+	return l
+}
+
+func firstLabel(labels map[string]string, names ...string) string {
+	for _, name := range names {
+		if v := labels[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
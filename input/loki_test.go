@@ -0,0 +1,105 @@
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/issue9/assert"
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func uvarint(v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
+
+// protoBytes 编码一个 wireBytes 字段
+func protoBytes(num int, data []byte) []byte {
+	buf := append(uvarint(uint64(num<<3|wireBytes)), uvarint(uint64(len(data)))...)
+	return append(buf, data...)
+}
+
+func protoVarint(num int, v uint64) []byte {
+	return append(uvarint(uint64(num<<3|wireVarint)), uvarint(v)...)
+}
+
+func TestLokiPush(t *testing.T) {
+	var logs []logstash.LogData
+	engine := echo.New()
+	Routes(engine, noPush(t), func(logData logstash.LogData) {
+		logs = append(logs, logData)
+	})
+
+	push := func(contentType string, body []byte) int {
+		req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// json
+	body := `{"streams":[{"stream":{"job":"api","level":"error","filename":"/data/logs/api.log","host":"prod-1"},
+		"values":[["1486714888942000000","NullPointerException"],["1486714889000000000","second",{"trace_id":"abc"}]]}]}`
+	assert.Equal(t, push("application/json; charset=utf-8", []byte(body)), http.StatusNoContent)
+	assert.Equal(t, len(logs), 2)
+	l := logs[0]
+	assert.Equal(t, l.Message, "NullPointerException")
+	assert.Equal(t, l.Level, "error")
+	assert.Equal(t, l.Source, "/data/logs/api.log")
+	assert.Equal(t, l.Beat.Hostname, "prod-1")
+	assert.Equal(t, l.InputType, "loki")
+	assert.Equal(t, l.Tags, []string{"/data/logs/api.log", "prod-1", "api", "error"})
+	assert.Equal(t, l.Field("job"), "api")
+	assert.True(t, l.Timestamp.Equal(time.Unix(0, 1486714888942000000)))
+	assert.Equal(t, logs[1].Field("trace_id"), "abc")
+
+	// 错误的行整个请求失败
+	logs = nil
+	assert.Equal(t, push("application/json", []byte(`{"streams":[{"stream":{},"values":[["1","a"],["x","b"]]}]}`)), http.StatusBadRequest)
+	assert.Equal(t, len(logs), 0)
+
+	// protobuf
+	ts := append(protoVarint(1, 1486714888), protoVarint(2, 942000000)...)
+	metadata := append(protoBytes(1, []byte("trace_id")), protoBytes(2, []byte("abc"))...)
+	entry := append(protoBytes(1, ts), protoBytes(2, []byte("from promtail"))...)
+	entry = append(entry, protoBytes(3, metadata)...)
+	stream := append(protoBytes(1, []byte(`{job="varlogs", filename="/var/log/a \"b\".log"}`)), protoBytes(2, entry)...)
+	stream = append(stream, protoVarint(3, 12345)...)
+	data := snappy.Encode(nil, protoBytes(1, stream))
+
+	logs = nil
+	assert.Equal(t, push("application/x-protobuf", data), http.StatusNoContent)
+	assert.Equal(t, len(logs), 1)
+	l = logs[0]
+	assert.Equal(t, l.Message, "from promtail")
+	assert.Equal(t, l.Source, `/var/log/a "b".log`)
+	assert.Equal(t, l.Tags, []string{`/var/log/a "b".log`, "varlogs"})
+	assert.Equal(t, l.Field("trace_id"), "abc")
+	assert.True(t, l.Timestamp.Equal(time.Unix(1486714888, 942000000)))
+
+	// 截断的 protobuf
+	assert.Equal(t, push("application/x-protobuf", snappy.Encode(nil, protoBytes(1, stream)[:10])), http.StatusBadRequest)
+	assert.Equal(t, push("application/x-protobuf", []byte("not snappy")), http.StatusBadRequest)
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := parseLabels(`{job="a", path="C:\\logs", msg="x=\"1\", y"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, labels, map[string]string{"job": "a", "path": `C:\logs`, "msg": `x="1", y`})
+
+	labels, err = parseLabels("{}")
+	assert.Nil(t, err)
+	assert.Equal(t, len(labels), 0)
+
+	for _, s := range []string{"", `job="a"`, `{job=a}`, `{job="a}`, `{job="a" app="b"}`, `{="a"}`} {
+		_, err := parseLabels(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -0,0 +1,499 @@
+package input
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// AnyValue 中 array_value 和 kvlist_value 最多嵌套的层数
+const maxOTLPDepth = 64
+
+// otlpRecord 一条 LogRecord 和它所属的 resource，scope
+type otlpRecord struct {
+	resource     map[string]interface{}
+	scopeName    string
+	scopeVersion string
+
+	timeUnixNano         uint64
+	observedTimeUnixNano uint64
+	severityNumber       int
+	severityText         string
+	body                 interface{}
+	attributes           map[string]interface{}
+	traceID              string
+	spanID               string
+	eventName            string
+}
+
+// otlpRoutes 注册 OTLP/HTTP 的 logs 接口，opentelemetry sdk 和 collector 的 otlphttp exporter 可以直接发送
+func otlpRoutes(engine *echo.Echo, handle Handler) {
+	engine.POST("/v1/logs", func(c echo.Context) error {
+		body, err := decompress(c.Request())
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		defer body.Close()
+
+		mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get("Content-Type"))
+		records, err := decodeOTLP(body, mediaType == "application/json")
+		if err != nil {
+			log.Warn("otlp error: ", err)
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		for _, record := range records {
+			handle(otlpLogData(record))
+		}
+
+		// 空的 ExportLogsServiceResponse
+		if mediaType == "application/json" {
+			return c.JSONBlob(http.StatusOK, []byte("{}"))
+		}
+		return c.Blob(http.StatusOK, "application/x-protobuf", nil)
+	})
+}
+
+// decodeOTLP 解析 ExportLogsServiceRequest，除了 json 都按 protobuf 解析
+func decodeOTLP(r io.Reader, isJSON bool) ([]otlpRecord, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBodySize {
+		return nil, fmt.Errorf("body too large, max %v bytes", maxBodySize)
+	}
+
+	if isJSON {
+		return decodeOTLPJSON(data)
+	}
+	return decodeOTLPProto(data)
+}
+
+// otlpLogData resource 和 LogRecord 的 attributes 保存为字段（LogRecord 的优先），
+// service.name 作为 tag 和 beat.name，severity 映射为 level，trace_id，span_id 为 16 进制
+func otlpLogData(r otlpRecord) logstash.LogData {
+	l := logstash.LogData{
+		Level:     r.severityText,
+		InputType: "otlp",
+		Fields:    make(map[string]interface{}, len(r.resource)+len(r.attributes)+6),
+	}
+
+	for k, v := range r.resource {
+		l.Fields[k] = v
+	}
+	for k, v := range r.attributes {
+		l.Fields[k] = v
+	}
+	set := func(name, value string) {
+		if value != "" {
+			l.Fields[name] = value
+		}
+	}
+	set("trace_id", r.traceID)
+	set("span_id", r.spanID)
+	set("otel.scope.name", r.scopeName)
+	set("otel.scope.version", r.scopeVersion)
+	set("event.name", r.eventName)
+	if r.severityNumber > 0 {
+		l.Fields["severity_number"] = json.Number(strconv.Itoa(r.severityNumber))
+	}
+
+	switch body := r.body.(type) {
+	case nil:
+	case string:
+		l.Message = body
+	default:
+		data, _ := json.Marshal(body)
+		l.Message = string(data)
+	}
+
+	if l.Level == "" {
+		l.Level = severityLevel(r.severityNumber)
+	}
+	if service, ok := r.resource["service.name"].(string); ok && service != "" {
+		l.Tags = append(l.Tags, service)
+		l.Beat.Name = service
+	}
+	if v, ok := l.Fields["log.file.path"].(string); ok {
+		l.Source = v
+	}
+
+	if r.timeUnixNano > 0 {
+		l.Timestamp = time.Unix(0, int64(r.timeUnixNano))
+	} else if r.observedTimeUnixNano > 0 {
+		l.Timestamp = time.Unix(0, int64(r.observedTimeUnixNano))
+	}
+
+	// host.name 等由 normalize 映射
+	normalize(&l)
+	return l
+}
+
+// severityLevel severity_number 对应的 level，1-4 TRACE，5-8 DEBUG，9-12 INFO，13-16 WARN，17-20 ERROR，21-24 FATAL
+func severityLevel(n int) string {
+	if n <= 0 || n > 24 {
+		return ""
+	}
+	return [...]string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}[(n-1)/4]
+}
+
+// otlp json 中 64 位整数是字符串，也兼容数字
+type otlpJSONInt string
+
+func (n *otlpJSONInt) UnmarshalJSON(data []byte) error {
+	*n = otlpJSONInt(strings.Trim(string(data), `"`))
+	return nil
+}
+
+func (n otlpJSONInt) uint64() (uint64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+type otlpJSONKeyValue struct {
+	Key   string        `json:"key"`
+	Value otlpJSONValue `json:"value"`
+}
+
+// otlpJSONValue AnyValue，只有一个字段有值
+type otlpJSONValue struct {
+	StringValue *string     `json:"stringValue"`
+	BoolValue   *bool       `json:"boolValue"`
+	IntValue    otlpJSONInt `json:"intValue"`
+	DoubleValue *float64    `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []otlpJSONValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpJSONKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue []byte `json:"bytesValue"`
+}
+
+// value 转换为和 json 解析的 log 一样的类型，数字为 json.Number
+func (v otlpJSONValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != "":
+		if _, err := strconv.ParseInt(string(v.IntValue), 10, 64); err != nil {
+			return string(v.IntValue)
+		}
+		return json.Number(v.IntValue)
+	case v.DoubleValue != nil:
+		return otlpDouble(*v.DoubleValue)
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i := range v.ArrayValue.Values {
+			values[i] = v.ArrayValue.Values[i].value()
+		}
+		return values
+	case v.KvlistValue != nil:
+		return otlpJSONAttributes(v.KvlistValue.Values)
+	case v.BytesValue != nil:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	}
+	return nil
+}
+
+func otlpJSONAttributes(kvs []otlpJSONKeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.value()
+	}
+	return m
+}
+
+// decodeOTLPJSON 解析 OTLP/JSON，字段名为 lowerCamelCase，trace_id 和 span_id 为 16 进制字符串
+func decodeOTLPJSON(data []byte) ([]otlpRecord, error) {
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []otlpJSONKeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+				} `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano         otlpJSONInt        `json:"timeUnixNano"`
+					ObservedTimeUnixNano otlpJSONInt        `json:"observedTimeUnixNano"`
+					SeverityNumber       int                `json:"severityNumber"`
+					SeverityText         string             `json:"severityText"`
+					Body                 otlpJSONValue      `json:"body"`
+					Attributes           []otlpJSONKeyValue `json:"attributes"`
+					TraceID              string             `json:"traceId"`
+					SpanID               string             `json:"spanId"`
+					EventName            string             `json:"eventName"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	var records []otlpRecord
+	for i, rl := range req.ResourceLogs {
+		resource := otlpJSONAttributes(rl.Resource.Attributes)
+		for j, sl := range rl.ScopeLogs {
+			for k, lr := range sl.LogRecords {
+				record := otlpRecord{
+					resource:       resource,
+					scopeName:      sl.Scope.Name,
+					scopeVersion:   sl.Scope.Version,
+					severityNumber: lr.SeverityNumber,
+					severityText:   lr.SeverityText,
+					body:           lr.Body.value(),
+					attributes:     otlpJSONAttributes(lr.Attributes),
+					traceID:        otlpID(lr.TraceID),
+					spanID:         otlpID(lr.SpanID),
+					eventName:      lr.EventName,
+				}
+
+				var err error
+				if record.timeUnixNano, err = lr.TimeUnixNano.uint64(); err == nil {
+					record.observedTimeUnixNano, err = lr.ObservedTimeUnixNano.uint64()
+				}
+				if err != nil {
+					return nil, fmt.Errorf("resourceLogs[%v].scopeLogs[%v].logRecords[%v]: %v", i, j, k, err)
+				}
+
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// otlpDouble NaN 和 Inf 不能作为 json 的数字，保存为字符串
+func otlpDouble(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// otlpID 全是 0 的 id 表示没有
+func otlpID(id string) string {
+	if strings.Trim(id, "0") == "" {
+		return ""
+	}
+	return strings.ToLower(id)
+}
+
+// decodeOTLPProto 解析 protobuf 的 ExportLogsServiceRequest
+//
+//	ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	Resource     { repeated KeyValue attributes = 1; }
+//	ScopeLogs    { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//	InstrumentationScope { string name = 1; string version = 2; }
+func decodeOTLPProto(data []byte) ([]otlpRecord, error) {
+	var records []otlpRecord
+	err := eachField(data, func(f protoField) error {
+		if f.num != 1 || f.wire != wireBytes {
+			return nil
+		}
+
+		resource := make(map[string]interface{})
+		var scopeLogs [][]byte
+		err := eachField(f.bytes, func(f protoField) error {
+			if f.wire != wireBytes {
+				return nil
+			}
+
+			switch f.num {
+			case 1:
+				return eachField(f.bytes, func(f protoField) error {
+					if f.num == 1 && f.wire == wireBytes {
+						return decodeOTLPKeyValue(f.bytes, resource, 0)
+					}
+					return nil
+				})
+			case 2:
+				// resource 可能在 scope_logs 后面，先保存下来
+				scopeLogs = append(scopeLogs, f.bytes)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, sl := range scopeLogs {
+			if records, err = decodeOTLPScopeLogs(sl, resource, records); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return records, err
+}
+
+func decodeOTLPScopeLogs(data []byte, resource map[string]interface{}, records []otlpRecord) ([]otlpRecord, error) {
+	var name, version string
+	var logRecords [][]byte
+	err := eachField(data, func(f protoField) error {
+		if f.wire != wireBytes {
+			return nil
+		}
+
+		switch f.num {
+		case 1:
+			return eachField(f.bytes, func(f protoField) error {
+				switch {
+				case f.num == 1 && f.wire == wireBytes:
+					name = string(f.bytes)
+				case f.num == 2 && f.wire == wireBytes:
+					version = string(f.bytes)
+				}
+				return nil
+			})
+		case 2:
+			logRecords = append(logRecords, f.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return records, err
+	}
+
+	for _, lr := range logRecords {
+		record := otlpRecord{resource: resource, scopeName: name, scopeVersion: version}
+		if err := decodeOTLPLogRecord(lr, &record); err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// decodeOTLPLogRecord
+//
+//	LogRecord {
+//	  fixed64 time_unix_nano = 1; fixed64 observed_time_unix_nano = 11;
+//	  SeverityNumber severity_number = 2; string severity_text = 3; AnyValue body = 5;
+//	  repeated KeyValue attributes = 6; bytes trace_id = 9; bytes span_id = 10; string event_name = 12;
+//	}
+func decodeOTLPLogRecord(data []byte, record *otlpRecord) error {
+	record.attributes = make(map[string]interface{})
+	return eachField(data, func(f protoField) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wire == wireFixed64:
+			record.timeUnixNano = f.value
+		case f.num == 11 && f.wire == wireFixed64:
+			record.observedTimeUnixNano = f.value
+		case f.num == 2 && f.wire == wireVarint:
+			record.severityNumber = int(f.value)
+		case f.num == 3 && f.wire == wireBytes:
+			record.severityText = string(f.bytes)
+		case f.num == 5 && f.wire == wireBytes:
+			record.body, err = decodeOTLPAnyValue(f.bytes, 0)
+		case f.num == 6 && f.wire == wireBytes:
+			err = decodeOTLPKeyValue(f.bytes, record.attributes, 0)
+		case f.num == 9 && f.wire == wireBytes:
+			record.traceID = otlpID(hex.EncodeToString(f.bytes))
+		case f.num == 10 && f.wire == wireBytes:
+			record.spanID = otlpID(hex.EncodeToString(f.bytes))
+		case f.num == 12 && f.wire == wireBytes:
+			record.eventName = string(f.bytes)
+		}
+		return err
+	})
+}
+
+// decodeOTLPKeyValue KeyValue { string key = 1; AnyValue value = 2; }，depth 为嵌套的层数
+func decodeOTLPKeyValue(data []byte, m map[string]interface{}, depth int) error {
+	var key string
+	var value interface{}
+	err := eachField(data, func(f protoField) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			key = string(f.bytes)
+		case f.num == 2 && f.wire == wireBytes:
+			value, err = decodeOTLPAnyValue(f.bytes, depth)
+		}
+		return err
+	})
+
+	m[key] = value
+	return err
+}
+
+// decodeOTLPAnyValue
+//
+//	AnyValue { oneof value { string string_value = 1; bool bool_value = 2; int64 int_value = 3; double double_value = 4;
+//	           ArrayValue array_value = 5; KeyValueList kvlist_value = 6; bytes bytes_value = 7; } }
+//	ArrayValue   { repeated AnyValue values = 1; }
+//	KeyValueList { repeated KeyValue values = 1; }
+func decodeOTLPAnyValue(data []byte, depth int) (interface{}, error) {
+	// 嵌套太深会栈溢出，recover 也救不回来
+	if depth >= maxOTLPDepth {
+		return nil, fmt.Errorf("value nested deeper than %v", maxOTLPDepth)
+	}
+
+	var value interface{}
+	err := eachField(data, func(f protoField) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			value = string(f.bytes)
+		case f.num == 2 && f.wire == wireVarint:
+			value = f.value != 0
+		case f.num == 3 && f.wire == wireVarint:
+			value = json.Number(strconv.FormatInt(int64(f.value), 10))
+		case f.num == 4 && f.wire == wireFixed64:
+			value = otlpDouble(f.float64())
+		case f.num == 5 && f.wire == wireBytes:
+			values := make([]interface{}, 0)
+			err = eachField(f.bytes, func(f protoField) error {
+				if f.num != 1 || f.wire != wireBytes {
+					return nil
+				}
+				v, err := decodeOTLPAnyValue(f.bytes, depth+1)
+				values = append(values, v)
+				return err
+			})
+			value = values
+		case f.num == 6 && f.wire == wireBytes:
+			m := make(map[string]interface{})
+			err = eachField(f.bytes, func(f protoField) error {
+				if f.num == 1 && f.wire == wireBytes {
+					return decodeOTLPKeyValue(f.bytes, m, depth+1)
+				}
+				return nil
+			})
+			value = m
+		case f.num == 7 && f.wire == wireBytes:
+			value = base64.StdEncoding.EncodeToString(f.bytes)
+		}
+		return err
+	})
+
+	return value, err
+}
@@ -0,0 +1,155 @@
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/labstack/echo"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func protoFixed64(num int, v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return append(uvarint(uint64(num<<3|wireFixed64)), buf...)
+}
+
+func protoKeyValue(key string, value []byte) []byte {
+	return protoBytes(1, append(protoBytes(1, []byte(key)), protoBytes(2, value)...))
+}
+
+func TestOTLPLogs(t *testing.T) {
+	var logs []logstash.LogData
+	engine := echo.New()
+	Routes(engine, noPush(t), func(logData logstash.LogData) {
+		logs = append(logs, logData)
+	})
+
+	post := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// json
+	body := `{"resourceLogs":[{"resource":{"attributes":[
+		{"key":"service.name","value":{"stringValue":"checkout"}},
+		{"key":"host.name","value":{"stringValue":"prod-1"}}]},
+	"scopeLogs":[{"scope":{"name":"io.opentelemetry.java"},"logRecords":[
+		{"timeUnixNano":"1486714888942000000","severityNumber":17,"body":{"stringValue":"payment failed"},
+		 "attributes":[{"key":"order.id","value":{"intValue":"42"}},{"key":"retry","value":{"boolValue":true}},
+		   {"key":"ratio","value":{"doubleValue":0.5}},{"key":"ids","value":{"arrayValue":{"values":[{"stringValue":"a"},{"intValue":1}]}}}],
+		 "traceId":"5B8EFFF798038103D269B633813FC60C","spanId":"EEE19B7EC3C1B174"},
+		{"observedTimeUnixNano":1486714889000000000,"severityText":"Warning","body":{"kvlistValue":{"values":[{"key":"msg","value":{"stringValue":"slow"}}]}},
+		 "traceId":"00000000000000000000000000000000"}]}]}]}`
+	rec := post("application/json", []byte(body))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Body.String(), "{}")
+	assert.Equal(t, len(logs), 2)
+
+	l := logs[0]
+	assert.Equal(t, l.Message, "payment failed")
+	assert.Equal(t, l.Level, "ERROR")
+	assert.Equal(t, l.InputType, "otlp")
+	assert.Equal(t, l.Tags, []string{"checkout"})
+	assert.Equal(t, l.Beat.Name, "checkout")
+	assert.Equal(t, l.Beat.Hostname, "prod-1")
+	assert.True(t, l.Timestamp.Equal(time.Unix(0, 1486714888942000000)))
+	assert.Equal(t, l.Field("trace_id"), "5b8efff798038103d269b633813fc60c")
+	assert.Equal(t, l.Field("span_id"), "eee19b7ec3c1b174")
+	assert.Equal(t, l.Field("order.id"), json.Number("42"))
+	assert.Equal(t, l.Field("retry"), true)
+	assert.Equal(t, l.Field("ratio"), json.Number("0.5"))
+	assert.Equal(t, l.Field("ids"), []interface{}{"a", json.Number("1")})
+	assert.Equal(t, l.Field("otel.scope.name"), "io.opentelemetry.java")
+	assert.Equal(t, l.Field("severity_number"), json.Number("17"))
+
+	l = logs[1]
+	assert.Equal(t, l.Message, `{"msg":"slow"}`)
+	assert.Equal(t, l.Level, "Warning")
+	assert.True(t, l.Timestamp.Equal(time.Unix(0, 1486714889000000000)))
+	_, ok := l.Lookup("trace_id")
+	assert.False(t, ok)
+
+	rec = post("application/json", []byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"timeUnixNano":"x"}]}]}]}`))
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+
+	// protobuf，resource 放在 scope_logs 后面
+	record := protoFixed64(1, 1486714888942000000)
+	record = append(record, protoVarint(2, 21)...)
+	record = append(record, protoBytes(5, protoBytes(1, []byte("out of memory")))...)
+	record = append(record, protoBytes(6, append(protoBytes(1, []byte("thread")), protoBytes(2, protoVarint(3, 7))...))...)
+	record = append(record, protoBytes(6, append(protoBytes(1, []byte("load")), protoBytes(2, protoFixed64(4, math.Float64bits(1.25)))...))...)
+	record = append(record, protoBytes(9, []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c})...)
+	record = append(record, protoBytes(10, []byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74})...)
+	scopeLogs := append(protoBytes(1, protoBytes(1, []byte("app"))), protoBytes(2, record)...)
+	resource := append(protoKeyValue("service.name", protoBytes(1, []byte("billing"))), protoKeyValue("host.name", protoBytes(1, []byte("prod-2")))...)
+	resourceLogs := append(protoBytes(2, scopeLogs), protoBytes(1, resource)...)
+
+	logs = nil
+	rec = post("application/x-protobuf", protoBytes(1, resourceLogs))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("Content-Type"), "application/x-protobuf")
+	assert.Equal(t, len(logs), 1)
+
+	l = logs[0]
+	assert.Equal(t, l.Message, "out of memory")
+	assert.Equal(t, l.Level, "FATAL")
+	assert.Equal(t, l.Tags, []string{"billing"})
+	assert.Equal(t, l.Beat.Hostname, "prod-2")
+	assert.True(t, l.Timestamp.Equal(time.Unix(0, 1486714888942000000)))
+	assert.Equal(t, l.Field("thread"), json.Number("7"))
+	assert.Equal(t, l.Field("load"), json.Number("1.25"))
+	assert.Equal(t, l.Field("trace_id"), "5b8efff798038103d269b633813fc60c")
+	assert.Equal(t, l.Field("span_id"), "eee19b7ec3c1b174")
+	assert.Equal(t, l.Field("otel.scope.name"), "app")
+
+	rec = post("application/x-protobuf", protoBytes(1, resourceLogs)[:20])
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+	assert.True(t, strings.Contains(rec.Body.String(), "truncated"))
+}
+
+func TestSeverityLevel(t *testing.T) {
+	assert.Equal(t, severityLevel(0), "")
+	assert.Equal(t, severityLevel(1), "TRACE")
+	assert.Equal(t, severityLevel(9), "INFO")
+	assert.Equal(t, severityLevel(16), "WARN")
+	assert.Equal(t, severityLevel(24), "FATAL")
+	assert.Equal(t, severityLevel(25), "")
+}
+
+func TestOTLPNested(t *testing.T) {
+	nested := func(depth int) []byte {
+		// AnyValue { ArrayValue { AnyValue { ... } } }
+		value := protoBytes(1, []byte("leaf"))
+		for i := 0; i < depth; i++ {
+			value = protoBytes(5, protoBytes(1, value))
+		}
+		record := protoBytes(5, value)
+		return protoBytes(1, protoBytes(2, protoBytes(2, record)))
+	}
+
+	records, err := decodeOTLPProto(nested(10))
+	assert.Nil(t, err)
+	assert.Equal(t, len(records), 1)
+
+	// 嵌套太深返回错误而不是栈溢出
+	engine := echo.New()
+	Routes(engine, noPush(t), func(logData logstash.LogData) {})
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(nested(5000)))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusBadRequest)
+	assert.True(t, strings.Contains(rec.Body.String(), "nested deeper"))
+}
@@ -0,0 +1,90 @@
+package input
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// protobuf 的 wire type
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("proto: truncated message")
+
+// protoReader 只解析 protobuf 的编码格式，不依赖生成的代码，够 loki 和 otlp 用
+type protoReader struct {
+	data []byte
+}
+
+// protoField 一个字段，wire 为 wireBytes 时值在 bytes，否则在 value
+type protoField struct {
+	num   int
+	wire  int
+	value uint64
+	bytes []byte
+}
+
+// next 读取下一个字段，没有字段时 ok 为 false
+func (r *protoReader) next() (f protoField, ok bool, err error) {
+	if len(r.data) == 0 {
+		return f, false, nil
+	}
+
+	tag, err := r.varint()
+	if err != nil {
+		return f, false, err
+	}
+	f.num, f.wire = int(tag>>3), int(tag&7)
+	if f.num <= 0 {
+		return f, false, fmt.Errorf("proto: invalid field number %v", f.num)
+	}
+
+	switch f.wire {
+	case wireVarint:
+		f.value, err = r.varint()
+	case wireFixed64:
+		if len(r.data) < 8 {
+			return f, false, errTruncated
+		}
+		f.value = binary.LittleEndian.Uint64(r.data)
+		r.data = r.data[8:]
+	case wireFixed32:
+		if len(r.data) < 4 {
+			return f, false, errTruncated
+		}
+		f.value = uint64(binary.LittleEndian.Uint32(r.data))
+		r.data = r.data[4:]
+	case wireBytes:
+		var n uint64
+		if n, err = r.varint(); err == nil {
+			if n > uint64(len(r.data)) {
+				return f, false, errTruncated
+			}
+			f.bytes, r.data = r.data[:n], r.data[n:]
+		}
+	default:
+		return f, false, fmt.Errorf("proto: unsupported wire type %v", f.wire)
+	}
+
+	return f, err == nil, err
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+// float64 wireFixed64 的 double
+func (f protoField) float64() float64 {
+	return math.Float64frombits(f.value)
+}
@@ -1,6 +1,14 @@
 package logstash
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/stacktrace"
+)
 
 //{"offset":656674,"level":"ERROR","input_type":"log","source":"/data/logs/console.2017-02-10.log","message":"2017-02-10T16:21:28.942+0800 ERROR [http-nio-8080-exec-5] org.apache.velocity.log:96 - ResourceManager : unable to find resource '404.json.vm' in any resource loader. ","type":"log","tags":["smartmatrix","console","beats_input_codec_plain_applied"],"@timestamp":"2017-02-10T08:21:28.942Z","@version":"1","beat":{"hostname":"ubuntu","name":"ubuntu","version":"5.1.1"},"host":"ubuntu","input_timestamp":"2017-02-22T04:06:07.197Z"}
 
@@ -13,6 +21,50 @@ type LogData struct {
 	Timestamp time.Time `json:"@timestamp"`
 	Beat      Beat      `json:"beat"`
 	Tags      []string  `json:"tags"`
+	// 指纹，相同的错误指纹相同，不是 logstash 的字段
+	Fingerprint string `json:"-"`
+	// 阈值告警时观察到的频率，比如 20次/60秒
+	Rate string `json:"-"`
+	// 应用名称，根据 filter 的规则提取
+	App string `json:"-"`
+	// 原始的 log，包括上面没有的字段，比如 offset，kubernetes，trace_id，数字为 json.Number
+	Fields map[string]interface{} `json:"-"`
+}
+
+// plainLogData 没有自定义 json 方法的 LogData，避免递归
+type plainLogData LogData
+
+// UnmarshalJSON 同时解析到字段和 Fields
+func (l *LogData) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*plainLogData)(l)); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(&l.Fields)
+}
+
+// MarshalJSON 输出 Fields 中的所有字段，同名的以 LogData 的字段为准
+func (l LogData) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(plainLogData(l))
+	if err != nil || len(l.Fields) == 0 {
+		return data, err
+	}
+
+	var typed map[string]interface{}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, len(l.Fields)+len(typed))
+	for k, v := range l.Fields {
+		m[k] = v
+	}
+	for k, v := range typed {
+		m[k] = v
+	}
+	return json.Marshal(m)
 }
 
 type Beat struct {
@@ -20,3 +72,83 @@ type Beat struct {
 	Version  string `json:"version"`
 	Hostname string `json:"hostname"`
 }
+
+// Stack 解析 message 中的堆栈，没有堆栈返回 nil，用于模板，比如 {{with .Stack}}{{.RootCause}}{{end}}
+func (l LogData) Stack() *stacktrace.Trace {
+	return stacktrace.Parse(l.Message)
+}
+
+// Field 根据字段路径获取字段的值，不存在返回空字符串，用于模板，比如 {{.Field "kubernetes.pod.name"}}
+func (l LogData) Field(path string) interface{} {
+	if v, ok := l.Lookup(path); ok {
+		return v
+	}
+
+	return ""
+}
+
+// Lookup 根据字段路径获取字段的值，路径用 . 分隔，比如 kubernetes.pod.name。
+// 先查找 LogData 的字段，tags 为 []string，其他为 string，再查找 Fields
+func (l LogData) Lookup(path string) (interface{}, bool) {
+	switch path {
+	case "level":
+		return l.Level, true
+	case "input_type":
+		return l.InputType, true
+	case "source":
+		return l.Source, true
+	case "message":
+		return l.Message, true
+	case "tags":
+		return l.Tags, true
+	case "host", "beat.hostname":
+		return l.Beat.Hostname, true
+	case "beat.name":
+		return l.Beat.Name, true
+	case "beat.version":
+		return l.Beat.Version, true
+	}
+
+	return LookupMap(l.Fields, path)
+}
+
+// LookupMap 在 m 中查找路径，key 本身可以包含 .，比如 {"kubernetes.pod": {"name": "x"}}
+func LookupMap(m map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := m[path]; ok {
+		return v, true
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if v, ok := LookupMap(sub, path[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// String 将字段的值转换为字符串，列表用 , 连接
+func String(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = String(v[i])
+		}
+		return strings.Join(s, ",")
+	}
+
+	return fmt.Sprint(v)
+}
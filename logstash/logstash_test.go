@@ -37,3 +37,29 @@ func TestMatchRegex(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFields(t *testing.T) {
+	var log LogData
+	if err := json.Unmarshal([]byte(text), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Field("beat.name") != "ubuntu" || String(log.Field("offset")) != "656674" || log.Field("not.exists") != "" {
+		t.Error("field error", log.Fields)
+	}
+
+	// 持久化后保留原始字段
+	log.Level = "WARN"
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded LogData
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Level != "WARN" || String(decoded.Field("input_timestamp")) != "2017-02-22T04:06:07.197Z" || !decoded.Timestamp.Equal(log.Timestamp) {
+		t.Error("marshal error", string(data))
+	}
+}
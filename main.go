@@ -1,38 +1,37 @@
 package main
 
 import (
-	"bytes"
-
-	"encoding/json"
+	"context"
 
 	"fmt"
-	"net/http"
 
 	"strings"
 	"sync"
 	"time"
 
-	"html/template"
-
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
-	"github.com/sdvdxl/dinghook"
 	"github.com/sdvdxl/go-tools/errors"
+	"github.com/sdvdxl/logstash-http-push/alert"
 	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/fingerprint"
+	"github.com/sdvdxl/logstash-http-push/input"
 	"github.com/sdvdxl/logstash-http-push/log"
 	"github.com/sdvdxl/logstash-http-push/logstash"
-	"github.com/sdvdxl/logstash-http-push/mail"
-	"io/ioutil"
-)
-
-const (
-	// logPathPrefix 日志路径前缀
-	logPathPrefix    = "/data/logs/"
-	logPathPrefixLen = len(logPathPrefix)
+	"github.com/sdvdxl/logstash-http-push/notifier"
+	"github.com/sdvdxl/logstash-http-push/queue"
+	"github.com/sdvdxl/logstash-http-push/rate"
+	"github.com/sdvdxl/logstash-http-push/silence"
+	"github.com/sdvdxl/logstash-http-push/stacktrace"
 )
 
 var (
-	dingMap map[string]*dinghook.DingQueue
+	// rates 阈值告警的滑动窗口
+	rates = rate.NewWindow()
+	// trackers 开启恢复通知的 filter 记录正在告警的错误，key 为 filter name
+	trackers     = make(map[string]*alert.Tracker)
+	trackersLock sync.RWMutex
+	silences *silence.Store
 )
 
 // AlarmInfo 告警记录
@@ -70,10 +69,6 @@ func (a *AlarmInfo) Reset() {
 	a.alarmInfoMap = make(map[string]uint64)
 }
 
-func init() {
-	dingMap = make(map[string]*dinghook.DingQueue)
-}
-
 func main() {
 	engine := echo.New()
 	engine.Use(middleware.Logger())
@@ -81,133 +76,47 @@ func main() {
 	cfg := config.Get()
 	log.Init(cfg)
 
-	for _, filter := range cfg.Filters {
-		log.Debug("config filter", filter.Name, "email and ding")
-		// 配置钉钉
-		if filter.Ding.Enable {
-			for _, d := range filter.Ding.Senders {
-				ding := &dinghook.DingQueue{Interval: 3, Limit: 1, Title: "【告警】", AccessToken: d.Token}
-				ding.Init()
-				go ding.Start()
-				dingMap[d.Token] = ding
-			}
-		}
-
-		// 配置 ticker
-		log.Info("init filter ", filter.Name, "ticker , duration", filter.Mail.Duration)
-		go func(filter *config.Filter) {
-			for {
-				select {
-				case <-filter.Mail.Ticker.C:
-					func() {
-						defer filter.Mail.Lock.Unlock()
-						log.Debug("ticker report")
-						filter.Mail.Lock.Lock()
-						if len(filter.Mail.MailMessages) == 0 {
-							return
-						}
-
-						sendSuccess := false
-
-						exCount := len(filter.Mail.MailMessages)
-						ignoreCount := exCount - Min(exCount, cfg.MaxMailSize)
-						var ignoreMsg string
-						if ignoreCount > 0 {
-							ignoreMsg = fmt.Sprint(" ignore: ", ignoreCount)
-						}
-						var message, errMsgs string
-						title := fmt.Sprint("[", cfg.DC, "] ", filter.Tags, filter.Mail.Duration, "秒聚合 [", exCount, "]", ignoreMsg)
-
-						sendMailMsgs := filter.Mail.MailMessages
-						if exCount > cfg.MaxMailSize {
-							sendMailMsgs = filter.Mail.MailMessages[:cfg.MaxMailSize]
-						}
-
-						message = strings.Join(sendMailMsgs, "<br><br><hr>")
-						filter.Mail.MailMessages = make([]string, 0, 10)
-						for range filter.Mail.Senders { // 如果失败，循环发送，直到配置的所有邮箱有成功的，或者全部失败
-							mailSender := filter.GetMail()
-
-							email := mail.Email{MailSender: mailSender, Subject: title, Message: message, ToPerson: filter.Mail.ToPersons}
-							if err := mail.SendEmail(email); err != nil {
-								errMsg := fmt.Sprint("send email error:", err, "\nsender:", filter.GetMail().Sender, "\nTo:", filter.Mail.ToPersons)
-								errMsgs += errMsg + "\n\n\n"
-								log.Error(errMsg)
-								mailSender = filter.GetNextMail()
-							} else {
-								sendSuccess = true
-								log.Info("send email success")
-								break
-							}
-						}
-
-						if !sendSuccess {
-							senders := ""
-							for _, m := range filter.Mail.Senders {
-								senders += m.Sender + " "
-							}
-
-							if len(message) > 15000 {
-								message = message[:15000]
-							}
-							messageToDing := fmt.Sprint("[", cfg.DC, "] [",
-								filter.Tags, "] \n所有 mail 都发送失败,请检查发送频率或者邮件信息，失败信息:\n",
-								errMsgs, "异常信息：\n", message)
-							log.Error("error sending email, message", messageToDing)
-							sendEmailErrorsToDings(filter, messageToDing)
-						}
-					}()
-				}
-			}
-		}(filter)
-
+	if cfg.Queue.Enable {
+		log.Info("open queue ", cfg.Queue.Path)
+		errors.Panic(queue.Open(cfg.Queue.Path, cfg.Queue.Retention, cfg.Queue.MaxSize))
 	}
 
-	engine.POST("/push", func(c echo.Context) error {
-		defer c.Request().Body.Close()
-		body, err := ioutil.ReadAll(c.Request().Body)
+	var err error
+	silences, err = silence.Open(cfg.SilenceFile)
+	errors.Panic(err)
+	silence.Routes(engine, silences)
 
-		logDatas, err := convertMessageToDataArray(cfg, body)
-		errors.Panic(err)
-		for i := range logDatas {
-			send(cfg, &logDatas[i])
+	errors.Panic(notifier.InitAll(cfg, cfg.ActiveFilters()))
+	initTrackers(cfg)
+	config.OnReload(func(cfg *config.Config) {
+		if err := notifier.InitAll(cfg, cfg.ActiveFilters()); err != nil {
+			log.Error("reload notifiers error: ", err)
 		}
-		return c.String(http.StatusOK, "")
+		initTrackers(cfg)
 	})
 
-	errors.Panic(engine.Start(cfg.Address))
-}
-
-// 将 message 转换成对象
-func convertMessageToDataArray(cfg *config.Config, message []byte) ([]logstash.LogData, error) {
-	var logDatas []logstash.LogData
-	if strings.HasPrefix(string(message), "[") {
-		log.Info("logstash pushed array message")
-		if err := json.Unmarshal(message, &logDatas); err != nil {
-			log.Error(err)
-			return nil, err
-		}
-	} else {
-		var logData logstash.LogData
-		if err := json.Unmarshal(message, &logData); err != nil {
-			log.Error(err)
-			return nil, err
-		} else {
-			logDatas = []logstash.LogData{logData}
-		}
+	// 只有 logstash 推送的时间需要按 timeZone 修正
+	push := func(logData logstash.LogData) {
+		logData.Timestamp = logData.Timestamp.Add(time.Hour * time.Duration(cfg.TimeZone))
+		send(cfg, &logData)
 	}
-
-	for i := range logDatas {
-		logDatas[i].Timestamp = logDatas[i].Timestamp.Add(time.Hour * time.Duration(cfg.TimeZone))
-
+	handle := func(logData logstash.LogData) {
+		send(cfg, &logData)
 	}
-	return logDatas, nil
+	input.Routes(engine, push, handle)
+	errors.Panic(input.ListenGELF(cfg.GELF.UDP, cfg.GELF.TCP, handle))
+
+	errors.Panic(engine.Start(cfg.Address))
 }
 
 // 检查log信息是否匹配
 func send(cfg *config.Config, logData *logstash.LogData) {
+	if s := silences.Silenced(*logData, time.Now()); s != nil {
+		log.Debug("silenced by ", s.ID, " ", s.Comment, ", message:", logData.Message)
+		return
+	}
 
-	matchFilter := cfg.GetFilter(logData.Tags, logData.Level)
+	matchFilter := cfg.Match(*logData)
 	if len(matchFilter) == 0 {
 		log.Warn("no filter matched")
 		return
@@ -215,7 +124,18 @@ func send(cfg *config.Config, logData *logstash.LogData) {
 
 	fmfs := make([]*config.Filter, 0, len(matchFilter))
 
+	now := time.Now()
 	for _, f := range matchFilter {
+		if f.InMaintenance(now) {
+			log.Debug("filter ", f.Name, " in maintenance, ignore message:", logData.Message)
+			continue
+		}
+
+		if !f.MatchExpr(*logData) {
+			log.Debug("filter ", f.Name, " expr not match, ignore message:", logData.Message)
+			continue
+		}
+
 		found := false
 		for _, i := range f.IgnoreContains {
 			if strings.Contains(logData.Message, i) {
@@ -230,98 +150,98 @@ func send(cfg *config.Config, logData *logstash.LogData) {
 		}
 	}
 
-	go sendDing(fmfs, *logData)
-	go sendEmail(fmfs, *logData)
-}
-
-func sendEmailErrorsToDings(filter *config.Filter, msg string) {
-	for _, d := range filter.Ding.Senders {
-		ding := dingMap[d.Token]
-		if ding == nil {
+	logData.Fingerprint = fingerprint.Of(*logData)
+	for _, f := range fmfs {
+		data := *logData
+		data.App = f.AppName(data)
+		if !checkRate(f, &data) {
 			continue
 		}
-		ding.Push(msg)
-	}
-}
-
-func sendDing(filters []*config.Filter, logData logstash.LogData) {
-	for _, filter := range filters {
-		if !filter.Ding.Enable {
-			log.Debug("ding ", filter.Ding.Name, " is disabled")
-			continue
-		}
-
-		if time.Now().Unix()-logData.Timestamp.Unix() > filter.Ding.IgnoreIfGtSecs {
-			log.Debug("ding message expired: ", filter.Ding.IgnoreIfGtSecs)
-			continue
-		}
-
-		msg := logData.Message
-		if filter.Ding.MatchRegex.MatchString(msg) {
-			idx := strings.Index(msg, " at")
-
-			if idx > 0 {
-				msg = msg[:idx]
-			}
-			title := logData.Source[strings.Index(logData.Source, logPathPrefix)+logPathPrefixLen : strings.Index(logData.Source, ".")]
-
-			for _, d := range filter.Ding.Senders {
-				ding := dingMap[d.Token]
-				if ding != nil {
-					ding.PushMessage(dinghook.SimpleMessage{Title: title, Content: getMessage(logData, false)})
-				}
-			}
 
+		trackersLock.RLock()
+		t := trackers[f.Name]
+		trackersLock.RUnlock()
+		if t != nil {
+			t.Observe(data, now)
 		}
+		go notify(f, data)
 	}
-
 }
 
-func sendEmail(filters []*config.Filter, logData logstash.LogData) {
-	for _, filter := range filters {
+// initTrackers 为开启恢复通知的 filter 创建 tracker，已经存在的更新配置并保留正在告警的错误，
+// 不再开启的关闭
+func initTrackers(cfg *config.Config) {
+	trackersLock.Lock()
+	defer trackersLock.Unlock()
 
-		if !filter.Mail.Enable {
+	enabled := make(map[string]bool)
+	for _, filter := range cfg.ActiveFilters() {
+		if !filter.Resolve.Enable {
 			continue
 		}
 
-		if time.Now().Unix()-logData.Timestamp.Unix() > filter.Ding.IgnoreIfGtSecs {
-			log.Debug("mail message expired: ", filter.Mail.IgnoreIfGtSecs)
-			continue
+		filter := filter
+		enabled[filter.Name] = true
+		quiet := time.Second * time.Duration(filter.Resolve.Quiet)
+		resolved := func(a *alert.Alert) {
+			notifier.SendText(context.Background(), filter, nil, resolvedMessage(cfg, filter, a))
+			notifier.Resolve(context.Background(), filter, a.LogData)
 		}
 
-		// 如果 ticker 不是 nil，则定时发送
-		message := getMessage(logData, true)
-		func() {
-			defer filter.Mail.Lock.Unlock()
-			filter.Mail.Lock.Lock()
-			filter.Mail.MailMessages = append(filter.Mail.MailMessages, message)
-		}()
+		if t := trackers[filter.Name]; t != nil {
+			t.Update(quiet, resolved)
+		} else {
+			trackers[filter.Name] = alert.NewTracker(quiet, resolved)
+		}
+	}
 
+	for name, t := range trackers {
+		if !enabled[name] {
+			t.Close()
+			delete(trackers, name)
+		}
 	}
 }
 
-func getMessage(logdata logstash.LogData, isHtml bool) string {
-	file := "templates/log.html"
-	if !isHtml {
-		file = "templates/log.txt"
-		idx := strings.Index(logdata.Message, " at")
+// resolvedMessage 恢复通知的内容
+func resolvedMessage(cfg *config.Config, filter *config.Filter, a *alert.Alert) string {
+	return fmt.Sprint("[", cfg.DC, "] ", filter.Tags, " 已恢复\n",
+		"持续时间: ", a.Duration(), ", 共 ", a.Count, " 次\n",
+		"开始时间: ", a.StartsAt.Format("2006-01-02 15:04:05"), "\n",
+		"最后出现: ", a.LastSeen.Format("2006-01-02 15:04:05"), "\n",
+		"App: ", a.App, "\n",
+		"LogFile: ", a.Source, "\n",
+		"LogMessage: ", stacktrace.Summary(a.Message))
+}
 
-		if idx > 0 {
-			logdata.Message = logdata.Message[:idx]
-		}
+// checkRate 检查是否达到 filter 的阈值，达到则将频率记录到 log 中
+func checkRate(filter *config.Filter, logData *logstash.LogData) bool {
+	if filter.Rate.Count <= 0 {
+		return true
 	}
 
-	tmpl, err := template.ParseFiles(file)
-	errors.Panic(err)
+	key := filter.Name
+	switch filter.Rate.GroupBy {
+	case "host":
+		key += "\x00" + logData.Beat.Hostname
+	case "source":
+		key += "\x00" + logData.Source
+	case "app":
+		key += "\x00" + logData.App
+	}
 
-	var contents bytes.Buffer
-	errors.Panic(tmpl.Execute(&contents, logdata))
-	return contents.String()
+	window := time.Second * time.Duration(filter.Rate.Window)
+	count := rates.Add(key, time.Now(), window)
+	if count < filter.Rate.Count {
+		log.Debug("filter ", filter.Name, " rate ", count, "/", filter.Rate.Count, " not reached")
+		return false
+	}
+
+	logData.Rate = fmt.Sprint(count, "次/", filter.Rate.Window, "秒")
+	return true
 }
 
-func Min(x, y int) int {
-	if x < y {
-		return x
-	}
-	return y
+// notify 将 log 发送到 filter 的所有通知
+func notify(filter *config.Filter, logData logstash.LogData) {
+	notifier.Notify(context.Background(), notifier.Batch{Filter: filter, Logs: []logstash.LogData{logData}})
 }
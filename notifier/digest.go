@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+	"github.com/sdvdxl/logstash-http-push/queue"
+)
+
+// digest 将 log 聚合，每隔 duration 秒调用一次 flush，
+// 开启持久化时 log 先写入队列文件，flush 后删除，重启后继续发送
+type digest struct {
+	name   string
+	lock   sync.Mutex
+	logs   []logstash.LogData
+	ids    []uint64
+	store  *queue.Queue
+	flush  func(logs []logstash.LogData)
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// queuedLog 写入队列文件的 log，App，Rate，Fingerprint 不会序列化到 log 中，单独保存
+type queuedLog struct {
+	Log         logstash.LogData `json:"log"`
+	App         string           `json:"app"`
+	Rate        string           `json:"rate"`
+	Fingerprint string           `json:"fingerprint"`
+}
+
+// newDigest name 用于持久化，需要保证重启后不变
+func newDigest(name string, duration int, flush func(logs []logstash.LogData)) *digest {
+	d := &digest{
+		name:   name,
+		logs:   make([]logstash.LogData, 0, 10),
+		store:  queue.Get("digest-" + name),
+		flush:  flush,
+		ticker: time.NewTicker(time.Second * time.Duration(duration)),
+		done:   make(chan struct{}),
+	}
+
+	d.replay()
+	log.Info("init ", name, " ticker, duration ", duration)
+	go d.loop()
+	return d
+}
+
+// replay 加载上次未发送的 log
+func (d *digest) replay() {
+	entries, err := d.store.Load()
+	if err != nil {
+		log.Error(d.name, " load queue error: ", err)
+	}
+
+	for _, e := range entries {
+		var q queuedLog
+		if err := json.Unmarshal(e.Data, &q); err != nil {
+			log.Error(d.name, " unmarshal queued log error: ", err)
+			d.store.Remove(e.ID)
+			continue
+		}
+
+		logData := q.Log
+		logData.App, logData.Rate, logData.Fingerprint = q.App, q.Rate, q.Fingerprint
+		d.logs = append(d.logs, logData)
+		d.ids = append(d.ids, e.ID)
+	}
+
+	if len(d.logs) > 0 {
+		log.Info(d.name, " replay ", len(d.logs), " logs")
+	}
+}
+
+func (d *digest) add(logData logstash.LogData) {
+	var id uint64
+	q := queuedLog{Log: logData, App: logData.App, Rate: logData.Rate, Fingerprint: logData.Fingerprint}
+	if data, err := json.Marshal(q); err != nil {
+		log.Error(d.name, " marshal log error: ", err)
+	} else if id, err = d.store.Push(data); err != nil {
+		log.Error(d.name, " persist log error: ", err)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.logs = append(d.logs, logData)
+	d.ids = append(d.ids, id)
+}
+
+func (d *digest) loop() {
+	for {
+		select {
+		case <-d.ticker.C:
+			log.Debug(d.name, " ticker report")
+			d.report()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// report 取出聚合的 log 并发送，发送期间新的 log 不会被阻塞
+func (d *digest) report() {
+	d.lock.Lock()
+	logs, ids := d.logs, d.ids
+	d.logs, d.ids = make([]logstash.LogData, 0, 10), nil
+	d.lock.Unlock()
+
+	if len(logs) == 0 {
+		return
+	}
+	d.flush(logs)
+
+	if err := d.store.Remove(ids...); err != nil {
+		log.Error(d.name, " remove queued logs error: ", err)
+	}
+}
+
+// close 停止定时器，并发送剩余的 log
+func (d *digest) close() {
+	d.ticker.Stop()
+	close(d.done)
+	d.report()
+}
+
+// digestTitle 聚合消息的标题，比如 [FRA] [TAGA]60秒聚合 [10] ignore: 2
+func digestTitle(cfg *config.Config, filter *config.Filter, duration, count, ignoreCount int) string {
+	var ignoreMsg string
+	if ignoreCount > 0 {
+		ignoreMsg = fmt.Sprint(" ignore: ", ignoreCount)
+	}
+
+	return fmt.Sprint("[", cfg.DC, "] ", filter.Tags, duration, "秒聚合 [", count, "]", ignoreMsg)
+}
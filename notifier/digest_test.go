@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+	"github.com/sdvdxl/logstash-http-push/queue"
+)
+
+func TestDigestReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, queue.Open(filepath.Join(dir, "queue.db"), 3600, 100))
+	defer queue.Close()
+
+	d := newDigest("test", 3600, func(logs []logstash.LogData) {})
+	d.add(logstash.LogData{Message: "timeout", App: "api", Rate: "20次/60秒", Fingerprint: "fp"})
+
+	// 重启后 App，Rate，Fingerprint 不丢失
+	replayed := newDigest("test", 3600, func(logs []logstash.LogData) {})
+	assert.Equal(t, len(replayed.logs), 1)
+	l := replayed.logs[0]
+	assert.Equal(t, l.Message, "timeout")
+	assert.Equal(t, l.App, "api")
+	assert.Equal(t, l.Rate, "20次/60秒")
+	assert.Equal(t, l.Fingerprint, "fp")
+
+	d.close()
+	replayed.close()
+}
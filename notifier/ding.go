@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/go-tools/encrypt"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const dingTitle = "【告警】"
+
+var (
+	dingURL = "https://oapi.dingtalk.com/robot/send"
+
+	dingLock sync.Mutex
+	// dingMap 相同 token 的 filter 共用一个队列
+	dingMap = make(map[string]*throttle)
+	// dingSenders token 对应的最新配置，重新加载配置后 secret 可能改变
+	dingSenders = make(map[string]config.DingSender)
+)
+
+func init() {
+	Register("ding", newDing)
+}
+
+type ding struct {
+	filter *config.Filter
+	size   int
+}
+
+func newDing(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	// 提前创建队列，重启后持久化的消息可以立即发送
+	for _, sender := range filter.Ding.Senders {
+		getDingQueue(sender, cfg.Queue.MaxSize)
+	}
+
+	return &ding{filter: filter, size: cfg.Queue.MaxSize}, nil
+}
+
+func getDingQueue(sender config.DingSender, size int) *throttle {
+	dingLock.Lock()
+	defer dingLock.Unlock()
+
+	dingSenders[sender.Token] = sender
+	queue := dingMap[sender.Token]
+	if queue == nil {
+		token := sender.Token
+		queue = newThrottle("ding-"+encrypt.MD5([]byte(token)), defaultInterval, defaultLimit, size, func(payload []byte) error {
+			dingLock.Lock()
+			sender := dingSenders[token]
+			dingLock.Unlock()
+			return postErrCode(dingSignURL(sender), json.RawMessage(payload))
+		})
+		dingMap[sender.Token] = queue
+	}
+
+	return queue
+}
+
+func (d *ding) Name() string {
+	return d.filter.Ding.Name
+}
+
+func (d *ding) Send(ctx context.Context, batch Batch) error {
+	info := d.filter.Ding
+	for _, logData := range batch.Logs {
+		if expired(logData, info.IgnoreIfGtSecs) {
+			log.Debug("ding message expired: ", info.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(info.MatchRegex, logData.Message) {
+			continue
+		}
+
+		d.push(d.message(logData))
+	}
+
+	return nil
+}
+
+// message 根据 msgType 生成消息
+func (d *ding) message(logData logstash.LogData) map[string]interface{} {
+	info := d.filter.Ding
+	title := appName(logData)
+	at := d.at(logData.Level)
+
+	switch info.MsgType {
+	case "markdown":
+		text := dingMarkdown(logData)
+		// markdown 需要在内容中包含 @手机号 才会 @ 到人
+		if mobiles, ok := at["atMobiles"].([]string); ok {
+			for _, m := range mobiles {
+				text += " @" + m
+			}
+		}
+		return map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]interface{}{"title": dingTitle + title, "text": text},
+			"at":       at,
+		}
+	case "actionCard":
+		actionTitle := info.ActionTitle
+		if actionTitle == "" {
+			actionTitle = "查看详情"
+		}
+		return map[string]interface{}{
+			"msgtype": "actionCard",
+			"actionCard": map[string]interface{}{
+				"title":          dingTitle + title,
+				"text":           dingMarkdown(logData),
+				"singleTitle":    actionTitle,
+				"singleURL":      info.ActionURL,
+				"btnOrientation": "0",
+			},
+		}
+	default:
+		return map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]interface{}{"content": fmt.Sprint(dingTitle, title, "\n", getMessage(logData, false))},
+			"at":      at,
+		}
+	}
+}
+
+// at 只有日志级别在 atLevels 中才 @
+func (d *ding) at(level string) map[string]interface{} {
+	info := d.filter.Ding
+	for _, l := range info.AtLevels {
+		if l == strings.ToUpper(level) {
+			return map[string]interface{}{"atMobiles": info.AtMobiles, "isAtAll": info.IsAtAll}
+		}
+	}
+
+	return map[string]interface{}{}
+}
+
+func (d *ding) SendText(ctx context.Context, text string) error {
+	d.push(map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]interface{}{"content": dingTitle + text},
+	})
+	return nil
+}
+
+func (d *ding) push(payload map[string]interface{}) {
+	for _, sender := range d.filter.Ding.Senders {
+		getDingQueue(sender, d.size).push(payload)
+	}
+}
+
+// Close 队列可能被其他 filter 共用，不关闭
+func (d *ding) Close() error {
+	return nil
+}
+
+// dingSignURL 配置了 secret 则加签，timestamp 为毫秒
+func dingSignURL(sender config.DingSender) string {
+	u := dingURL + "?access_token=" + url.QueryEscape(sender.Token)
+	if sender.Secret == "" {
+		return u
+	}
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	return fmt.Sprint(u, "&timestamp=", timestamp, "&sign=", url.QueryEscape(dingSign(timestamp, sender.Secret)))
+}
+
+// dingSign 签名为 timestamp + "\n" + secret 以 secret 为密钥做 HmacSHA256 后 base64
+func dingSign(timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%v\n%v", timestamp, secret)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func dingMarkdown(logData logstash.LogData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### [%v] %v\n\n", logData.Level, appName(logData))
+	fmt.Fprintf(&b, "- Host: %v\n", logData.Beat.Hostname)
+	fmt.Fprintf(&b, "- Tags: %v\n", strings.Join(logData.Tags, ", "))
+	fmt.Fprintf(&b, "- LogFile: %v\n", logData.Source)
+	if logData.Rate != "" {
+		fmt.Fprintf(&b, "- Rate: %v\n", logData.Rate)
+	}
+	fmt.Fprintf(&b, "- Timestamp: %v\n\n", logData.Timestamp)
+	fmt.Fprintf(&b, "> %v\n", cutStack(logData.Message))
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestDingSign(t *testing.T) {
+	assert.Equal(t, dingSign(1599360473000, "SEC000000000000000000000"), "kjOV9n/gRl1AeeXnxqNB9wcQisVPOw+8ce62niAcJZ4=")
+	assert.Equal(t, dingSignURL(config.DingSender{Token: "a b"}), dingURL+"?access_token=a+b")
+}
+
+func TestDingPayload(t *testing.T) {
+	server, r := newRecorder(`{"errcode":0,"errmsg":"ok"}`)
+	defer server.Close()
+	defaultURL := dingURL
+	dingURL = server.URL
+	defer func() { dingURL = defaultURL }()
+
+	sender := config.DingSender{Token: "ding-test-token", Secret: "SEC000000000000000000000"}
+	filter := &config.Filter{Name: "test", Ding: config.DingInfo{
+		IgnoreIfGtSecs: 60,
+		MsgType:        "markdown",
+		AtMobiles:      []string{"13800000000"},
+		AtLevels:       []string{"ERROR"},
+		Senders:        []config.DingSender{sender},
+	}}
+	n, err := newDing(&config.Config{}, filter)
+	assert.Nil(t, err)
+
+	logData := logstash.LogData{Message: "timeout", Level: "error", App: "api", Timestamp: time.Now()}
+	assert.Nil(t, n.Send(context.Background(), Batch{Filter: filter, Logs: []logstash.LogData{logData}}))
+	getDingQueue(sender, 0).flush()
+
+	assert.Equal(t, len(r.bodies), 1)
+	body := r.bodies[0]
+	assert.Equal(t, body["msgtype"], "markdown")
+	markdown := body["markdown"].(map[string]interface{})
+	assert.Equal(t, markdown["title"], dingTitle+"api")
+	assert.Equal(t, markdown["text"], dingMarkdown(logData)+" @13800000000")
+	at := body["at"].(map[string]interface{})
+	assert.Equal(t, at["atMobiles"], []interface{}{"13800000000"})
+
+	// 加签
+	query := r.queries[0]
+	assert.Equal(t, query.Get("access_token"), "ding-test-token")
+	timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, query.Get("sign"), dingSign(timestamp, sender.Secret))
+}
@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdvdxl/go-tools/encrypt"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// 飞书卡片文本最大长度
+	feishuTextLimit = 4000
+)
+
+func init() {
+	Register("feishu", newFeishu)
+}
+
+type feishu struct {
+	filter *config.Filter
+	// 每个 webhook 一个队列
+	throttles []*throttle
+}
+
+func newFeishu(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	f := &feishu{filter: filter}
+	for _, sender := range filter.Feishu.Senders {
+		sender := sender
+		f.throttles = append(f.throttles, newThrottle(f.Name()+"-"+encrypt.MD5([]byte(sender.WebhookURL)), filter.Feishu.Interval, filter.Feishu.Limit, cfg.Queue.MaxSize, func(payload []byte) error {
+			return postFeishu(sender, payload)
+		}))
+	}
+	return f, nil
+}
+
+func (f *feishu) Name() string {
+	return f.filter.Name + "-feishu"
+}
+
+func (f *feishu) Send(ctx context.Context, batch Batch) error {
+	for _, logData := range batch.Logs {
+		if expired(logData, f.filter.Feishu.IgnoreIfGtSecs) {
+			log.Debug("feishu message expired: ", f.filter.Feishu.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(f.filter.Feishu.MatchRegex, logData.Message) {
+			continue
+		}
+
+		f.push(map[string]interface{}{"msg_type": "interactive", "card": feishuCard(logData)})
+	}
+
+	return nil
+}
+
+func (f *feishu) SendText(ctx context.Context, text string) error {
+	f.push(map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]interface{}{"text": truncate(text, feishuTextLimit)},
+	})
+	return nil
+}
+
+func (f *feishu) push(msg map[string]interface{}) {
+	for _, t := range f.throttles {
+		t.push(msg)
+	}
+}
+
+func (f *feishu) Close() error {
+	for _, t := range f.throttles {
+		t.close()
+	}
+	return nil
+}
+
+// postFeishu 签名和时间相关，发送时再计算，飞书出错时也返回 200，需要检查 code
+func postFeishu(sender config.FeishuSender, msg []byte) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(msg, &payload); err != nil {
+		return err
+	}
+
+	if sender.Secret != "" {
+		timestamp := time.Now().Unix()
+		payload["timestamp"] = strconv.FormatInt(timestamp, 10)
+		payload["sign"] = feishuSign(timestamp, sender.Secret)
+	}
+
+	body, err := postJSON(context.Background(), sender.WebhookURL, payload)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+
+	if result.Code != 0 {
+		return fmt.Errorf("feishu response code: %v, msg: %v", result.Code, result.Msg)
+	}
+
+	return nil
+}
+
+// feishuSign 以 timestamp + "\n" + secret 为密钥，对空字符串做 HmacSHA256 后 base64
+func feishuSign(timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(fmt.Sprintf("%v\n%v", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// feishuCard 消息卡片，标题颜色根据日志级别区分
+func feishuCard(logData logstash.LogData) map[string]interface{} {
+	summary, stack := splitStack(logData.Message)
+	elements := []map[string]interface{}{
+		{
+			"tag": "div",
+			"fields": []map[string]interface{}{
+				feishuField("Level", logData.Level),
+				feishuField("Host", logData.Beat.Hostname),
+				feishuField("Tags", strings.Join(logData.Tags, ", ")),
+				feishuField("Timestamp", logData.Timestamp.String()),
+				feishuField("LogFile", logData.Source),
+			},
+		},
+		{"tag": "hr"},
+		{
+			"tag":  "div",
+			"text": map[string]interface{}{"tag": "plain_text", "content": truncate(summary, feishuTextLimit)},
+		},
+	}
+
+	if stack != "" {
+		elements = append(elements, map[string]interface{}{
+			"tag":      "note",
+			"elements": []map[string]interface{}{{"tag": "plain_text", "content": truncate(stack, feishuTextLimit)}},
+		})
+	}
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true},
+		"header": map[string]interface{}{
+			"title":    map[string]interface{}{"tag": "plain_text", "content": fmt.Sprint("[", logData.Level, "] ", appName(logData))},
+			"template": feishuTemplate(logData.Level),
+		},
+		"elements": elements,
+	}
+}
+
+func feishuField(name, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"is_short": true,
+		"text":     map[string]interface{}{"tag": "lark_md", "content": "**" + name + "**\n" + value},
+	}
+}
+
+// feishuTemplate 卡片标题颜色
+func feishuTemplate(level string) string {
+	switch strings.ToUpper(level) {
+	case "FATAL", "ERROR":
+		return "red"
+	case "WARN", "WARNING":
+		return "orange"
+	default:
+		return "blue"
+	}
+}
@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestFeishuSign(t *testing.T) {
+	assert.Equal(t, feishuSign(1599360473, "SEC000000000000000000000"), "tZVM4ouDwq+2bRx/q1XWi581sbrH/l2hxBttPdp/a9A=")
+}
+
+func TestFeishuPayload(t *testing.T) {
+	server, r := newRecorder(`{"code":0,"msg":"success"}`)
+	defer server.Close()
+
+	sender := config.FeishuSender{WebhookURL: server.URL, Secret: "SEC000000000000000000000"}
+	filter := &config.Filter{Name: "test", Feishu: config.FeishuInfo{
+		IgnoreIfGtSecs: 60,
+		Interval:       3600,
+		Senders:        []config.FeishuSender{sender},
+	}}
+	n, err := newFeishu(&config.Config{}, filter)
+	assert.Nil(t, err)
+	f := n.(*feishu)
+	defer f.Close()
+
+	logData := logstash.LogData{Message: "timeout", Level: "WARN", App: "api", Timestamp: time.Now()}
+	assert.Nil(t, f.Send(context.Background(), Batch{Filter: filter, Logs: []logstash.LogData{logData}}))
+	f.throttles[0].flush()
+
+	assert.Equal(t, len(r.bodies), 1)
+	body := r.bodies[0]
+	assert.Equal(t, body["msg_type"], "interactive")
+	header := body["card"].(map[string]interface{})["header"].(map[string]interface{})
+	assert.Equal(t, header["template"], "orange")
+	assert.Equal(t, header["title"].(map[string]interface{})["content"], "[WARN] api")
+
+	timestamp, err := strconv.ParseInt(body["timestamp"].(string), 10, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, body["sign"], feishuSign(timestamp, sender.Secret))
+
+	// code 不为 0 时返回错误
+	errServer, _ := newRecorder(`{"code":19021,"msg":"sign match fail"}`)
+	defer errServer.Close()
+	assert.NotNil(t, postFeishu(config.FeishuSender{WebhookURL: errServer.URL}, []byte(`{"msg_type":"text"}`)))
+}
@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON 将 v 序列化为 json post 到 url，返回响应内容，非 2xx 返回错误
+func postJSON(ctx context.Context, url string, v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, fmt.Errorf("post %v status: %v, response: %s", url, resp.StatusCode, respBody)
+	}
+
+	return respBody, nil
+}
+
+// postErrCode 钉钉和企业微信出错时也返回 200，需要检查 errcode
+func postErrCode(url string, payload interface{}) error {
+	body, err := postJSON(context.Background(), url, payload)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+
+	if result.ErrCode != 0 {
+		return fmt.Errorf("post %v errcode: %v, errmsg: %v", url, result.ErrCode, result.ErrMsg)
+	}
+
+	return nil
+}
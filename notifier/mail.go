@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/fingerprint"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+	"github.com/sdvdxl/logstash-http-push/mail"
+)
+
+func init() {
+	Register("mail", newMail)
+}
+
+// mailNotifier 将消息聚合后每隔 Duration 秒发送一封邮件
+type mailNotifier struct {
+	cfg    *config.Config
+	filter *config.Filter
+	digest *digest
+}
+
+func newMail(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	m := &mailNotifier{cfg: cfg, filter: filter}
+	m.digest = newDigest(m.Name(), filter.Mail.Duration, m.flush)
+	return m, nil
+}
+
+func (m *mailNotifier) Name() string {
+	return m.filter.Name + "-mail"
+}
+
+func (m *mailNotifier) Send(ctx context.Context, batch Batch) error {
+	for _, logData := range batch.Logs {
+		if expired(logData, m.filter.Mail.IgnoreIfGtSecs) {
+			log.Debug("mail message expired: ", m.filter.Mail.IgnoreIfGtSecs)
+			continue
+		}
+
+		m.digest.add(logData)
+	}
+
+	return nil
+}
+
+func (m *mailNotifier) Close() error {
+	m.digest.close()
+	return nil
+}
+
+// flush 发送聚合的邮件
+func (m *mailNotifier) flush(logs []logstash.LogData) {
+	cfg, filter := m.cfg, m.filter
+
+	// 相同的错误只显示一次，MaxMailSize 限制的是不同错误的数量
+	groups := fingerprint.GroupBy(logs)
+	exCount := len(groups)
+	title := digestTitle(cfg, filter, filter.Mail.Duration, len(logs), exCount-min(exCount, cfg.MaxMailSize))
+	if exCount > cfg.MaxMailSize {
+		groups = groups[:cfg.MaxMailSize]
+	}
+
+	sendMailMsgs := make([]string, 0, len(groups))
+	for _, g := range groups {
+		sendMailMsgs = append(sendMailMsgs, render(htmlTemplate, g))
+	}
+
+	m.send(title, strings.Join(sendMailMsgs, "<br><br><hr>"))
+}
+
+// SendText 立即发送一封邮件，标题为第一行
+func (m *mailNotifier) SendText(ctx context.Context, text string) error {
+	title := text
+	if idx := strings.Index(title, "\n"); idx > 0 {
+		title = title[:idx]
+	}
+
+	m.send(truncate(title, 200), strings.Replace(html.EscapeString(text), "\n", "<br>", -1))
+	return nil
+}
+
+// send 发送邮件，如果所有邮箱都发送失败，通过 filter 的其他通知报告
+func (m *mailNotifier) send(title, message string) {
+	cfg, filter := m.cfg, m.filter
+	sendSuccess := false
+
+	var errMsgs string
+	for range filter.Mail.Senders { // 如果失败，循环发送，直到配置的所有邮箱有成功的，或者全部失败
+		mailSender := filter.GetMail()
+
+		email := mail.Email{MailSender: mailSender, Subject: title, Message: message, ToPerson: filter.Mail.ToPersons}
+		if err := mail.SendEmail(email); err != nil {
+			errMsg := fmt.Sprint("send email error:", err, "\nsender:", mailSender.Sender, "\nTo:", filter.Mail.ToPersons)
+			errMsgs += errMsg + "\n\n\n"
+			log.Error(errMsg)
+			filter.GetNextMail()
+		} else {
+			sendSuccess = true
+			log.Info("send email success")
+			break
+		}
+	}
+
+	if !sendSuccess {
+		if len(message) > 15000 {
+			message = message[:15000]
+		}
+		messageToDing := fmt.Sprint("[", cfg.DC, "] [",
+			filter.Tags, "] \n所有 mail 都发送失败,请检查发送频率或者邮件信息，失败信息:\n",
+			errMsgs, "异常信息：\n", message)
+		log.Error("error sending email, message", messageToDing)
+		SendText(context.Background(), filter, m, messageToDing)
+	}
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
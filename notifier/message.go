@@ -0,0 +1,106 @@
+package notifier
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sdvdxl/go-tools/errors"
+	"github.com/sdvdxl/logstash-http-push/fingerprint"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+	"github.com/sdvdxl/logstash-http-push/stacktrace"
+)
+
+const (
+	htmlTemplate = "templates/log.html"
+	textTemplate = "templates/log.txt"
+)
+
+// getMessage 用模板渲染 log 信息，非 html 格式的会截掉堆栈信息
+func getMessage(logdata logstash.LogData, isHtml bool) string {
+	if isHtml {
+		return render(htmlTemplate, fingerprint.Group{LogData: logdata, Count: 1})
+	}
+
+	logdata.Message = cutStack(logdata.Message)
+	return render(textTemplate, logdata)
+}
+
+// render 用模板文件渲染数据，模板中可以用 {{field "kubernetes.pod.name"}} 获取 log 的原始字段
+func render(file string, data interface{}) string {
+	tmpl, err := template.New(filepath.Base(file)).Funcs(fieldFuncs(data)).ParseFiles(file)
+	errors.Panic(err)
+
+	var contents bytes.Buffer
+	errors.Panic(tmpl.Execute(&contents, data))
+	return contents.String()
+}
+
+// fieldFuncs 绑定到 data 的 field 函数
+func fieldFuncs(data interface{}) map[string]interface{} {
+	field := func(path string) interface{} { return "" }
+	if f, ok := data.(interface {
+		Field(path string) interface{}
+	}); ok {
+		field = f.Field
+	}
+
+	return map[string]interface{}{"field": field}
+}
+
+// cutStack 去掉堆栈帧，保留异常和根本原因
+func cutStack(msg string) string {
+	return stacktrace.Summary(msg)
+}
+
+// splitStack 将消息分为摘要和堆栈
+func splitStack(msg string) (summary, stack string) {
+	return stacktrace.Split(msg)
+}
+
+// expired log 时间和现在相比超过 secs 秒
+func expired(logData logstash.LogData, secs int64) bool {
+	return time.Now().Unix()-logData.Timestamp.Unix() > secs
+}
+
+// match 正则为空则全部匹配
+func match(re *regexp.Regexp, msg string) bool {
+	return re == nil || re.MatchString(msg)
+}
+
+// appName 应用名称，没有提取过（比如从队列中恢复的）则为 unknown
+func appName(logData logstash.LogData) string {
+	if logData.App == "" {
+		return "unknown"
+	}
+
+	return logData.App
+}
+
+// truncate 将 s 截断到最多 n 个字节，不截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// levelColor 根据日志级别返回颜色
+func levelColor(level string) string {
+	switch strings.ToUpper(level) {
+	case "FATAL", "ERROR":
+		return "#d00000"
+	case "WARN", "WARNING":
+		return "#ffa500"
+	default:
+		return "#439fe0"
+	}
+}
@@ -0,0 +1,210 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// Batch 一次通知的内容，filter 为匹配到的 filter
+type Batch struct {
+	Filter *config.Filter
+	Logs   []logstash.LogData
+}
+
+// Notifier 通知渠道，比如钉钉、邮件
+type Notifier interface {
+	// Name 通知名称，用于日志
+	Name() string
+	// Send 发送通知，具体是立即发送还是聚合后发送由实现决定
+	Send(ctx context.Context, batch Batch) error
+	// Close 释放资源，未发送的消息尽量发送出去
+	Close() error
+}
+
+// TextSender 可以发送纯文本消息的通知，用于报告其他通知的失败信息
+type TextSender interface {
+	SendText(ctx context.Context, text string) error
+}
+
+// Resolver 可以发送恢复事件的通知，比如关闭 pagerduty 的 incident
+type Resolver interface {
+	Resolve(ctx context.Context, logData logstash.LogData) error
+}
+
+// Factory 根据 filter 配置创建通知
+type Factory func(cfg *config.Config, filter *config.Filter) (Notifier, error)
+
+// entry 通知和它的类型
+type entry struct {
+	typ string
+	Notifier
+}
+
+var (
+	lock      sync.RWMutex
+	factories = make(map[string]Factory)
+	notifiers = make(map[string][]entry)
+)
+
+// Register 注册通知类型，重复注册会 panic
+func Register(typ string, factory Factory) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, exists := factories[typ]; exists {
+		panic("notifier " + typ + " already registered")
+	}
+	factories[typ] = factory
+}
+
+// New 创建指定类型的通知
+func New(typ string, cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	lock.RLock()
+	factory, exists := factories[typ]
+	lock.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("filter %v: unknown notifier type %v", filter.Name, typ)
+	}
+
+	return factory(cfg, filter)
+}
+
+// Init 按照 filter.Notifiers 创建 filter 的所有通知，替换已经存在的通知后再关闭它们，
+// 旧的通知未发送的消息交给新的通知继续发送，失败时保留旧的通知
+func Init(cfg *config.Config, filter *config.Filter) error {
+	ns := make([]entry, 0, len(filter.Notifiers))
+	for _, typ := range filter.Notifiers {
+		n, err := New(typ, cfg, filter)
+		if err != nil {
+			for _, created := range ns {
+				created.Close()
+			}
+			return err
+		}
+		ns = append(ns, entry{typ: typ, Notifier: n})
+	}
+
+	// 先替换再关闭旧的，替换期间的通知不会丢失，旧的通知未发送的消息交给新的
+	lock.Lock()
+	old := notifiers[filter.Name]
+	notifiers[filter.Name] = ns
+	lock.Unlock()
+	for _, n := range old {
+		n.Close()
+	}
+
+	return nil
+}
+
+// InitAll 创建所有 filter 的通知，关闭不在 filters 中的通知，用于启动和重新加载配置
+func InitAll(cfg *config.Config, filters []*config.Filter) error {
+	names := make(map[string]bool, len(filters))
+	for _, filter := range filters {
+		names[filter.Name] = true
+	}
+
+	var removed []entry
+	lock.Lock()
+	for name, ns := range notifiers {
+		if !names[name] {
+			removed = append(removed, ns...)
+			delete(notifiers, name)
+		}
+	}
+	lock.Unlock()
+	for _, n := range removed {
+		n.Close()
+	}
+
+	for _, filter := range filters {
+		log.Debug("config filter ", filter.Name, " notifiers ", filter.Notifiers)
+		if err := Init(cfg, filter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get 获取 filter 的所有通知
+func Get(filter *config.Filter) []Notifier {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	ns := make([]Notifier, 0, len(notifiers[filter.Name]))
+	for _, e := range notifiers[filter.Name] {
+		ns = append(ns, e.Notifier)
+	}
+	return ns
+}
+
+// Notify 将 batch 发送到 filter 的所有通知，安静时段内需要静默的通知跳过
+func Notify(ctx context.Context, batch Batch) {
+	lock.RLock()
+	ns := notifiers[batch.Filter.Name]
+	lock.RUnlock()
+
+	now := time.Now()
+	for _, n := range ns {
+		if batch.Filter.InQuietHours(n.typ, now) {
+			log.Debug("notifier ", n.Name(), " in quiet hours")
+			continue
+		}
+
+		if err := n.Send(ctx, batch); err != nil {
+			log.Error("notifier ", n.Name(), " send error: ", err)
+		}
+	}
+}
+
+// Close 关闭所有通知
+func Close() {
+	lock.Lock()
+	defer lock.Unlock()
+	for name, ns := range notifiers {
+		for _, n := range ns {
+			n.Close()
+		}
+		delete(notifiers, name)
+	}
+}
+
+// SendText 将文本发送到 filter 中除 except 以外所有支持文本的通知，安静时段内需要静默的通知跳过
+func SendText(ctx context.Context, filter *config.Filter, except Notifier, text string) {
+	lock.RLock()
+	ns := notifiers[filter.Name]
+	lock.RUnlock()
+
+	now := time.Now()
+	for _, n := range ns {
+		if n.Notifier == except {
+			continue
+		}
+		if filter.InQuietHours(n.typ, now) {
+			log.Debug("notifier ", n.Name(), " in quiet hours")
+			continue
+		}
+
+		if ts, ok := n.Notifier.(TextSender); ok {
+			ts.SendText(ctx, text)
+		}
+	}
+}
+
+// Resolve 告警恢复时通知 filter 中所有支持恢复事件的通知，logData 为告警的第一条 log
+func Resolve(ctx context.Context, filter *config.Filter, logData logstash.LogData) {
+	for _, n := range Get(filter) {
+		if r, ok := n.(Resolver); ok {
+			if err := r.Resolve(ctx, logData); err != nil {
+				log.Error("notifier ", n.Name(), " resolve error: ", err)
+			}
+		}
+	}
+}
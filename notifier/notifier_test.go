@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// recorder 记录 httptest 收到的请求
+type recorder struct {
+	lock    sync.Mutex
+	bodies  []map[string]interface{}
+	queries []url.Values
+}
+
+func newRecorder(response string) (*httptest.Server, *recorder) {
+	r := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+
+		r.lock.Lock()
+		r.bodies = append(r.bodies, body)
+		r.queries = append(r.queries, req.URL.Query())
+		r.lock.Unlock()
+		w.Write([]byte(response))
+	}))
+	return server, r
+}
+
+type fakeNotifier struct {
+	name   string
+	sent   int
+	texts  []string
+	closed bool
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+
+func (f *fakeNotifier) Send(ctx context.Context, batch Batch) error {
+	f.sent += len(batch.Logs)
+	return nil
+}
+
+func (f *fakeNotifier) SendText(ctx context.Context, text string) error {
+	f.texts = append(f.texts, text)
+	return nil
+}
+
+func (f *fakeNotifier) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	var created []*fakeNotifier
+	Register("fake", func(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+		n := &fakeNotifier{name: filter.Name + "-fake"}
+		created = append(created, n)
+		return n, nil
+	})
+	Register("broken", func(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+		return nil, errors.New("broken")
+	})
+	assert.Panic(t, func() { Register("fake", nil) })
+
+	_, err := New("unknown", nil, &config.Filter{Name: "a"})
+	assert.NotNil(t, err)
+
+	a := &config.Filter{Name: "a", Notifiers: []string{"fake", "fake"}}
+	b := &config.Filter{Name: "b", Notifiers: []string{"fake"}}
+	assert.Nil(t, InitAll(nil, []*config.Filter{a, b}))
+	assert.Equal(t, len(Get(a)), 2)
+	assert.Equal(t, len(Get(b)), 1)
+
+	Notify(context.Background(), Batch{Filter: a, Logs: []logstash.LogData{{Message: "x"}}})
+	assert.Equal(t, created[0].sent, 1)
+	assert.Equal(t, created[1].sent, 1)
+	assert.Equal(t, created[2].sent, 0)
+
+	SendText(context.Background(), a, created[0], "text")
+	assert.Equal(t, len(created[0].texts), 0)
+	assert.Equal(t, created[1].texts, []string{"text"})
+
+	// 重新加载，旧的通知关闭，不在配置中的 filter 的通知也关闭
+	assert.Nil(t, InitAll(nil, []*config.Filter{a}))
+	assert.True(t, created[0].closed)
+	assert.True(t, created[2].closed)
+	assert.Equal(t, len(Get(b)), 0)
+	assert.Equal(t, len(created), 5)
+
+	// 创建失败时已经创建的通知关闭
+	assert.NotNil(t, Init(nil, &config.Filter{Name: "c", Notifiers: []string{"fake", "broken"}}))
+	assert.True(t, created[5].closed)
+	assert.Equal(t, len(Get(&config.Filter{Name: "c"})), 0)
+
+	Close()
+	assert.True(t, created[3].closed)
+	assert.Equal(t, len(Get(a)), 0)
+}
@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/fingerprint"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// pagerduty summary 最大长度
+	pagerDutySummaryLimit = 1024
+)
+
+func init() {
+	Register("pagerduty", newPagerDuty)
+}
+
+// pagerDuty 发送 trigger 事件，相同指纹的错误使用相同的 dedup_key，合并为一个 incident，
+// 恢复时发送相同 dedup_key 的 resolve 事件
+type pagerDuty struct {
+	filter *config.Filter
+}
+
+func newPagerDuty(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	return &pagerDuty{filter: filter}, nil
+}
+
+func (p *pagerDuty) Name() string {
+	return p.filter.Name + "-pagerduty"
+}
+
+func (p *pagerDuty) Send(ctx context.Context, batch Batch) error {
+	info := p.filter.PagerDuty
+	for _, logData := range batch.Logs {
+		if expired(logData, info.IgnoreIfGtSecs) {
+			log.Debug("pagerduty message expired: ", info.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(info.MatchRegex, logData.Message) {
+			continue
+		}
+
+		for _, sender := range info.Senders {
+			if _, err := postJSON(ctx, info.URL, p.event(sender, logData)); err != nil {
+				log.Error(p.Name(), " send error: ", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Resolve 发送 resolve 事件关闭 incident
+func (p *pagerDuty) Resolve(ctx context.Context, logData logstash.LogData) error {
+	info := p.filter.PagerDuty
+	if !match(info.MatchRegex, logData.Message) {
+		return nil
+	}
+
+	var lastErr error
+	for _, sender := range info.Senders {
+		event := map[string]interface{}{
+			"routing_key":  sender.RoutingKey,
+			"event_action": "resolve",
+			"dedup_key":    fingerprint.Get(logData),
+		}
+		if _, err := postJSON(ctx, info.URL, event); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (p *pagerDuty) event(sender config.PagerDutySender, logData logstash.LogData) map[string]interface{} {
+	info := p.filter.PagerDuty
+	source := logData.Beat.Hostname
+	if source == "" {
+		source = logData.Source
+	}
+
+	event := map[string]interface{}{
+		"routing_key":  sender.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    fingerprint.Get(logData),
+		"payload": map[string]interface{}{
+			"summary":   truncate(cutStack(logData.Message), pagerDutySummaryLimit),
+			"source":    source,
+			"severity":  pagerDutySeverity(logData.Level),
+			"timestamp": logData.Timestamp.Format(time.RFC3339),
+			"component": appName(logData),
+			"group":     strings.Join(p.filter.Tags, ","),
+			"custom_details": map[string]interface{}{
+				"host":    logData.Beat.Hostname,
+				"tags":    logData.Tags,
+				"source":  logData.Source,
+				"message": logData.Message,
+			},
+		},
+	}
+
+	if info.Client != "" {
+		event["client"] = info.Client
+	}
+	if info.ClientURL != "" {
+		event["client_url"] = info.ClientURL
+	}
+
+	return event
+}
+
+func (p *pagerDuty) Close() error {
+	return nil
+}
+
+// pagerDutySeverity severity 只能是 critical，error，warning，info
+func pagerDutySeverity(level string) string {
+	switch strings.ToUpper(level) {
+	case "FATAL":
+		return "critical"
+	case "ERROR":
+		return "error"
+	case "WARN", "WARNING":
+		return "warning"
+	default:
+		return "info"
+	}
+}
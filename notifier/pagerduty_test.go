@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestPagerDutyResolve(t *testing.T) {
+	server, r := newRecorder(`{"status":"success"}`)
+	defer server.Close()
+
+	filter := &config.Filter{Name: "test", PagerDuty: config.PagerDutyInfo{
+		IgnoreIfGtSecs: 60,
+		URL:            server.URL,
+		Senders:        []config.PagerDutySender{{RoutingKey: "key"}},
+	}}
+	n, err := newPagerDuty(&config.Config{}, filter)
+	assert.Nil(t, err)
+
+	logData := logstash.LogData{Message: "timeout", Level: "ERROR", Fingerprint: "fp", Timestamp: time.Now()}
+	assert.Nil(t, n.Send(context.Background(), Batch{Filter: filter, Logs: []logstash.LogData{logData}}))
+	assert.Nil(t, n.(Resolver).Resolve(context.Background(), logData))
+
+	// resolve 和 trigger 使用相同的 dedup_key
+	assert.Equal(t, len(r.bodies), 2)
+	assert.Equal(t, r.bodies[0]["event_action"], "trigger")
+	assert.Equal(t, r.bodies[1]["event_action"], "resolve")
+	assert.Equal(t, r.bodies[1]["routing_key"], "key")
+	assert.Equal(t, r.bodies[1]["dedup_key"], r.bodies[0]["dedup_key"])
+}
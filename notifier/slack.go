@@ -0,0 +1,150 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sdvdxl/go-tools/encrypt"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// slack section 文本最大长度
+	slackTextLimit = 3000
+)
+
+func init() {
+	Register("slack", newSlack)
+}
+
+type slack struct {
+	filter *config.Filter
+	// 每个 webhook 一个队列
+	throttles []*throttle
+}
+
+func newSlack(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	s := &slack{filter: filter}
+	for _, sender := range filter.Slack.Senders {
+		url := sender.WebhookURL
+		s.throttles = append(s.throttles, newThrottle(s.Name()+"-"+encrypt.MD5([]byte(url)), filter.Slack.Interval, filter.Slack.Limit, cfg.Queue.MaxSize, func(payload []byte) error {
+			_, err := postJSON(context.Background(), url, json.RawMessage(payload))
+			return err
+		}))
+	}
+	return s, nil
+}
+
+func (s *slack) Name() string {
+	return s.filter.Name + "-slack"
+}
+
+func (s *slack) Send(ctx context.Context, batch Batch) error {
+	for _, logData := range batch.Logs {
+		if expired(logData, s.filter.Slack.IgnoreIfGtSecs) {
+			log.Debug("slack message expired: ", s.filter.Slack.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(s.filter.Slack.MatchRegex, logData.Message) {
+			continue
+		}
+
+		s.push(slackBlocks(logData), slackAttachments(logData), cutStack(logData.Message))
+	}
+
+	return nil
+}
+
+func (s *slack) SendText(ctx context.Context, text string) error {
+	s.push(nil, nil, text)
+	return nil
+}
+
+func (s *slack) push(blocks, attachments []map[string]interface{}, text string) {
+	for i, sender := range s.filter.Slack.Senders {
+		payload := map[string]interface{}{"text": truncate(text, slackTextLimit)}
+		if blocks != nil {
+			payload["blocks"] = blocks
+		}
+		if attachments != nil {
+			payload["attachments"] = attachments
+		}
+		if sender.Channel != "" {
+			payload["channel"] = sender.Channel
+		}
+		if sender.Username != "" {
+			payload["username"] = sender.Username
+		}
+		if sender.IconEmoji != "" {
+			payload["icon_emoji"] = sender.IconEmoji
+		}
+		if sender.IconURL != "" {
+			payload["icon_url"] = sender.IconURL
+		}
+
+		s.throttles[i].push(payload)
+	}
+}
+
+func (s *slack) Close() error {
+	for _, t := range s.throttles {
+		t.close()
+	}
+	return nil
+}
+
+// slackBlocks 标题，level，host，source 和异常摘要
+func slackBlocks(logData logstash.LogData) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"type": "header",
+			"text": slackText("plain_text", truncate(appName(logData), 150)),
+		},
+		{
+			"type": "section",
+			"fields": []map[string]interface{}{
+				slackText("mrkdwn", "*Level*\n"+logData.Level),
+				slackText("mrkdwn", "*Host*\n"+logData.Beat.Hostname),
+				slackText("mrkdwn", "*Source*\n"+logData.Source),
+				slackText("mrkdwn", "*Timestamp*\n"+logData.Timestamp.String()),
+			},
+		},
+		{
+			"type": "section",
+			"text": slackText("mrkdwn", truncate(cutStack(logData.Message), slackTextLimit)),
+		},
+		{
+			"type":     "context",
+			"elements": []map[string]interface{}{slackText("mrkdwn", fmt.Sprint("Tags: ", strings.Join(logData.Tags, ", ")))},
+		},
+	}
+}
+
+// slackAttachments 堆栈信息放在 attachment 中，slack 会自动折叠
+func slackAttachments(logData logstash.LogData) []map[string]interface{} {
+	_, stack := splitStack(logData.Message)
+	if stack == "" {
+		return nil
+	}
+
+	return []map[string]interface{}{
+		{
+			"color": levelColor(logData.Level),
+			"blocks": []map[string]interface{}{
+				{
+					"type": "section",
+					"text": slackText("mrkdwn", "```"+truncate(stack, slackTextLimit-6)+"```"),
+				},
+			},
+		},
+	}
+}
+
+func slackText(typ, text string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "text": text}
+}
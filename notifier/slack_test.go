@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestSlackPayload(t *testing.T) {
+	server, r := newRecorder("ok")
+	defer server.Close()
+
+	filter := &config.Filter{Name: "test", Slack: config.SlackInfo{
+		IgnoreIfGtSecs: 60,
+		Interval:       3600,
+		Senders:        []config.SlackSender{{WebhookURL: server.URL, Channel: "#alerts", Username: "bot"}},
+	}}
+	n, err := newSlack(&config.Config{}, filter)
+	assert.Nil(t, err)
+	s := n.(*slack)
+	defer s.Close()
+
+	logData := logstash.LogData{Message: "java.lang.NullPointerException\n\tat com.example.Foo.bar(Foo.java:1)", Level: "ERROR", App: "api", Timestamp: time.Now()}
+	assert.Nil(t, s.Send(context.Background(), Batch{Filter: filter, Logs: []logstash.LogData{logData}}))
+	s.throttles[0].flush()
+
+	assert.Equal(t, len(r.bodies), 1)
+	body := r.bodies[0]
+	assert.Equal(t, body["channel"], "#alerts")
+	assert.Equal(t, body["username"], "bot")
+	assert.Equal(t, body["text"], "java.lang.NullPointerException")
+	blocks := body["blocks"].([]interface{})
+	assert.Equal(t, len(blocks), 4)
+	header := blocks[0].(map[string]interface{})["text"].(map[string]interface{})
+	assert.Equal(t, header["text"], "api")
+	attachment := body["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, attachment["color"], levelColor("ERROR"))
+
+	// 纯文本没有 blocks
+	assert.Nil(t, s.SendText(context.Background(), "resolved"))
+	s.throttles[0].flush()
+	assert.Equal(t, len(r.bodies), 2)
+	assert.Equal(t, r.bodies[1]["text"], "resolved")
+	_, ok := r.bodies[1]["blocks"]
+	assert.False(t, ok)
+}
+
+func TestSlackReload(t *testing.T) {
+	server, r := newRecorder("ok")
+	defer server.Close()
+
+	filter := &config.Filter{Name: "reload", Notifiers: []string{"slack"}, Slack: config.SlackInfo{
+		IgnoreIfGtSecs: 60,
+		Interval:       3600,
+		Senders:        []config.SlackSender{{WebhookURL: server.URL}},
+	}}
+	cfg := &config.Config{}
+	assert.Nil(t, Init(cfg, filter))
+	defer Close()
+
+	// 没有开启队列文件，重新加载配置后未发送的消息继续发送
+	logs := []logstash.LogData{{Message: "a", Timestamp: time.Now()}, {Message: "b", Timestamp: time.Now()}, {Message: "c", Timestamp: time.Now()}}
+	Notify(context.Background(), Batch{Filter: filter, Logs: logs})
+	assert.Nil(t, Init(cfg, filter))
+
+	s := Get(filter)[0].(*slack)
+	s.throttles[0].limit = 10
+	s.throttles[0].flush()
+	assert.Equal(t, len(r.bodies), 3)
+}
@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// teams 单条日志消息最大长度，避免超过 28KB 的限制
+	teamsTextLimit = 1000
+)
+
+func init() {
+	Register("teams", newTeams)
+}
+
+// teams 和邮件一样聚合后发送 Adaptive Card
+type teams struct {
+	cfg    *config.Config
+	filter *config.Filter
+	digest *digest
+}
+
+func newTeams(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	t := &teams{cfg: cfg, filter: filter}
+	t.digest = newDigest(t.Name(), filter.Teams.Duration, t.flush)
+	return t, nil
+}
+
+func (t *teams) Name() string {
+	return t.filter.Name + "-teams"
+}
+
+func (t *teams) Send(ctx context.Context, batch Batch) error {
+	for _, logData := range batch.Logs {
+		if expired(logData, t.filter.Teams.IgnoreIfGtSecs) {
+			log.Debug("teams message expired: ", t.filter.Teams.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(t.filter.Teams.MatchRegex, logData.Message) {
+			continue
+		}
+
+		t.digest.add(logData)
+	}
+
+	return nil
+}
+
+func (t *teams) SendText(ctx context.Context, text string) error {
+	t.post(teamsCard([]map[string]interface{}{teamsTextBlock(truncate(text, teamsTextLimit*10), false)}))
+	return nil
+}
+
+func (t *teams) Close() error {
+	t.digest.close()
+	return nil
+}
+
+func (t *teams) flush(logs []logstash.LogData) {
+	info := t.filter.Teams
+	count := len(logs)
+	title := digestTitle(t.cfg, t.filter, info.Duration, count, count-min(count, info.MaxSize))
+	if count > info.MaxSize {
+		logs = logs[:info.MaxSize]
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "wrap": true},
+	}
+	for _, logData := range logs {
+		body = append(body, teamsLogContainer(logData))
+	}
+
+	t.post(teamsCard(body))
+}
+
+func (t *teams) post(card map[string]interface{}) {
+	for _, sender := range t.filter.Teams.Senders {
+		if _, err := postJSON(context.Background(), sender.WebhookURL, card); err != nil {
+			log.Error(t.Name(), " send error: ", err)
+		}
+	}
+}
+
+// teamsCard incoming webhook 需要将 Adaptive Card 放在 attachments 中
+func teamsCard(body []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+					"msteams": map[string]interface{}{"width": "Full"},
+				},
+			},
+		},
+	}
+}
+
+// teamsLogContainer 和 templates/log.txt 中的字段一致
+func teamsLogContainer(logData logstash.LogData) map[string]interface{} {
+	facts := []map[string]interface{}{
+		{"title": "Level", "value": logData.Level},
+		{"title": "Timestamp", "value": logData.Timestamp.String()},
+		{"title": "Host", "value": logData.Beat.Hostname},
+		{"title": "Beat", "value": fmt.Sprint(logData.Beat.Name, " ", logData.Beat.Version)},
+		{"title": "Tags", "value": strings.Join(logData.Tags, ", ")},
+		{"title": "Source", "value": logData.Source},
+	}
+	if logData.App != "" {
+		facts = append([]map[string]interface{}{{"title": "App", "value": logData.App}}, facts...)
+	}
+
+	return map[string]interface{}{
+		"type":      "Container",
+		"separator": true,
+		"style":     teamsStyle(logData.Level),
+		"items": []map[string]interface{}{
+			{"type": "FactSet", "facts": facts},
+			teamsTextBlock(truncate(cutStack(logData.Message), teamsTextLimit), true),
+		},
+	}
+}
+
+func teamsTextBlock(text string, monospace bool) map[string]interface{} {
+	block := map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true}
+	if monospace {
+		block["fontType"] = "Monospace"
+	}
+
+	return block
+}
+
+// teamsStyle Container 的样式
+func teamsStyle(level string) string {
+	switch strings.ToUpper(level) {
+	case "FATAL", "ERROR":
+		return "attention"
+	case "WARN", "WARNING":
+		return "warning"
+	default:
+		return "default"
+	}
+}
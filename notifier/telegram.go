@@ -0,0 +1,154 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	telegramURL = "https://api.telegram.org/bot"
+	// telegram 消息最大 4096 个字符，转义后最多变为两倍，所以按 2000 个字符拆分
+	telegramSplitLimit = 2000
+	// 429 最多重试次数
+	telegramMaxRetries = 3
+)
+
+// telegramEscaper MarkdownV2 需要转义的字符
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func init() {
+	Register("telegram", newTelegram)
+}
+
+type telegram struct {
+	filter   *config.Filter
+	throttle *throttle
+}
+
+func newTelegram(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	t := &telegram{filter: filter}
+	t.throttle = newThrottle(t.Name(), filter.Telegram.Interval, filter.Telegram.Limit, cfg.Queue.MaxSize, t.post)
+	return t, nil
+}
+
+func (t *telegram) Name() string {
+	return t.filter.Name + "-telegram"
+}
+
+func (t *telegram) Send(ctx context.Context, batch Batch) error {
+	info := t.filter.Telegram
+	for _, logData := range batch.Logs {
+		if expired(logData, info.IgnoreIfGtSecs) {
+			log.Debug("telegram message expired: ", info.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(info.MatchRegex, logData.Message) {
+			continue
+		}
+
+		title := fmt.Sprint("[", logData.Level, "] ", appName(logData))
+		t.push(title, telegramBody(logData))
+	}
+
+	return nil
+}
+
+func (t *telegram) SendText(ctx context.Context, text string) error {
+	t.push("", text)
+	return nil
+}
+
+// push 消息过长时拆分为多条，标题只在第一条中加粗显示
+func (t *telegram) push(title, text string) {
+	for i, part := range splitText(text, telegramSplitLimit) {
+		part = telegramEscaper.Replace(part)
+		if i == 0 && title != "" {
+			part = "*" + telegramEscaper.Replace(title) + "*\n" + part
+		}
+
+		for _, chatID := range t.filter.Telegram.ChatIDs {
+			payload := map[string]interface{}{
+				"chat_id":                  chatID,
+				"text":                     part,
+				"parse_mode":               "MarkdownV2",
+				"disable_web_page_preview": true,
+			}
+			t.throttle.push(payload)
+		}
+	}
+}
+
+// post 返回 429 时等待 retry_after 秒后重试
+func (t *telegram) post(payload []byte) error {
+	url := telegramURL + t.filter.Telegram.BotToken + "/sendMessage"
+	for i := 0; ; i++ {
+		body, err := postJSON(context.Background(), url, json.RawMessage(payload))
+		if err == nil {
+			return nil
+		}
+
+		var result struct {
+			ErrorCode   int    `json:"error_code"`
+			Description string `json:"description"`
+			Parameters  struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		if json.Unmarshal(body, &result) != nil || result.ErrorCode != 429 || i >= telegramMaxRetries {
+			// 错误信息中包含 token，不输出
+			return fmt.Errorf("telegram sendMessage error: %v %v", result.ErrorCode, result.Description)
+		}
+
+		log.Warn(t.Name(), " too many requests, retry after ", result.Parameters.RetryAfter, "s")
+		time.Sleep(time.Second * time.Duration(result.Parameters.RetryAfter))
+	}
+}
+
+func (t *telegram) Close() error {
+	t.throttle.close()
+	return nil
+}
+
+func telegramBody(logData logstash.LogData) string {
+	return fmt.Sprint(
+		"Host: ", logData.Beat.Hostname, "\n",
+		"Tags: ", strings.Join(logData.Tags, ", "), "\n",
+		"LogFile: ", logData.Source, "\n",
+		"Timestamp: ", logData.Timestamp, "\n\n",
+		logData.Message,
+	)
+}
+
+// splitText 按字符数拆分，尽量在换行处拆分
+func splitText(text string, limit int) []string {
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		// 前 limit 个字符的字节长度
+		end := 0
+		for i := 0; i < limit; i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+
+		if idx := strings.LastIndex(text[:end], "\n"); idx > 0 {
+			end = idx + 1
+		}
+
+		parts = append(parts, text[:end])
+		text = text[end:]
+	}
+
+	return append(parts, text)
+}
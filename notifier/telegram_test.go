@@ -0,0 +1,25 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/issue9/assert"
+)
+
+func TestTelegramEscape(t *testing.T) {
+	assert.Equal(t, telegramEscaper.Replace(`a.b_c [x](y) 1+1=2!`), `a\.b\_c \[x\]\(y\) 1\+1\=2\!`)
+}
+
+func TestSplitText(t *testing.T) {
+	text := strings.Repeat("异常信息\n", 1000)
+	parts := splitText(text, 2000)
+	assert.Equal(t, strings.Join(parts, ""), text)
+	for _, p := range parts {
+		assert.True(t, utf8.RuneCountInString(p) <= 2000)
+		assert.True(t, strings.HasSuffix(p, "\n"))
+	}
+
+	assert.Equal(t, len(splitText("short", 2000)), 1)
+}
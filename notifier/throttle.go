@@ -0,0 +1,224 @@
+package notifier
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/queue"
+)
+
+const (
+	// 和钉钉队列一致，每 3 秒最多发送 1 条
+	defaultInterval = 3
+	defaultLimit    = 1
+	// 发送失败后最多重试的次数，超过则丢弃
+	maxThrottleRetries = 5
+)
+
+var (
+	throttlesLock sync.Mutex
+	// throttles 每个名称正在使用的队列，重新加载配置时旧的队列关闭后把未发送的消息交给新的队列
+	throttles = make(map[string]*throttle)
+)
+
+type throttleItem struct {
+	id      uint64
+	payload []byte
+	retries int
+}
+
+// throttle 限流队列，每隔 interval 秒最多发送 limit 条消息，
+// 开启持久化时消息先写入队列文件，发送后删除，重启后继续发送
+type throttle struct {
+	name    string
+	limit   int
+	size    int
+	send    func(payload []byte) error
+	store   *queue.Queue
+	lock    sync.Mutex
+	items   []throttleItem
+	ticker  *time.Ticker
+	done    chan struct{}
+	stopped chan struct{}
+	// prev 被替换的同名队列，closed 是否已经关闭，由 throttlesLock 保护
+	prev   *throttle
+	closed bool
+}
+
+// newThrottle name 用于持久化，需要保证重启后不变，size 为最多保留的消息数，和队列文件一致
+func newThrottle(name string, interval, limit, size int, send func(payload []byte) error) *throttle {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	t := &throttle{
+		name:    name,
+		limit:   limit,
+		size:    size,
+		send:    send,
+		store:   queue.Get("throttle-" + name),
+		ticker:  time.NewTicker(time.Second * time.Duration(interval)),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	throttlesLock.Lock()
+	t.prev = throttles[name]
+	throttles[name] = t
+	throttlesLock.Unlock()
+
+	// 替换旧的队列时，旧队列的消息在它关闭时交过来，不能再从队列文件加载，否则会重复发送
+	if t.prev == nil {
+		entries, err := t.store.Load()
+		if err != nil {
+			log.Error(name, " load queue error: ", err)
+		}
+		for _, e := range entries {
+			t.items = append(t.items, throttleItem{id: e.ID, payload: e.Data})
+		}
+		if len(entries) > 0 {
+			log.Info(name, " replay ", len(entries), " messages")
+		}
+	}
+
+	go t.loop()
+	return t
+}
+
+// push 将消息序列化后放入队列
+func (t *throttle) push(v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Error(t.name, " marshal message error: ", err)
+		return
+	}
+
+	id, err := t.store.Push(payload)
+	if err != nil {
+		log.Error(t.name, " persist message error: ", err)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// 队列文件写入时已经删除了最早的记录
+	if t.size > 0 && len(t.items) >= t.size {
+		log.Warn(t.name, " queue is full, drop oldest message")
+		t.items = t.items[len(t.items)-t.size+1:]
+	}
+	t.items = append(t.items, throttleItem{id: id, payload: payload})
+}
+
+func (t *throttle) pop() []throttleItem {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	n := len(t.items)
+	if n > t.limit {
+		n = t.limit
+	}
+	items := t.items[:n:n]
+	t.items = t.items[n:]
+	return items
+}
+
+// requeue 将发送失败的消息放回队列头部，下次继续发送
+func (t *throttle) requeue(items []throttleItem) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.items = append(items, t.items...)
+}
+
+// flush 发送一批消息，失败时剩余的消息放回队列，超过重试次数的丢弃
+func (t *throttle) flush() {
+	items := t.pop()
+	for i, item := range items {
+		err := t.send(item.payload)
+		if err == nil {
+			t.store.Remove(item.id)
+			continue
+		}
+
+		item.retries++
+		if item.retries >= maxThrottleRetries {
+			log.Error(t.name, " send error, drop message after ", item.retries, " retries: ", err)
+			t.store.Remove(item.id)
+			continue
+		}
+
+		log.Error(t.name, " send error, retry later: ", err)
+		items[i] = item
+		t.requeue(items[i:])
+		return
+	}
+}
+
+func (t *throttle) loop() {
+	defer close(t.stopped)
+	for {
+		select {
+		case <-t.ticker.C:
+			t.flush()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// close 停止发送并等待正在发送的消息。有同名的新队列时未发送的消息交给新队列，
+// 否则保留在队列文件中
+func (t *throttle) close() {
+	t.ticker.Stop()
+	close(t.done)
+	<-t.stopped
+
+	throttlesLock.Lock()
+	t.closed = true
+	next := throttles[t.name]
+	older := false
+	if next == t {
+		delete(throttles, t.name)
+		next = nil
+		// 新的队列创建后又关闭了（比如重新加载配置失败），旧的队列继续使用
+		if t.prev != nil && !t.prev.closed {
+			next = t.prev
+			throttles[t.name] = next
+		}
+	} else if next != nil {
+		if next.prev == t {
+			next.prev = nil
+		}
+		older = true
+	}
+	t.prev = nil
+	throttlesLock.Unlock()
+
+	if next == nil {
+		return
+	}
+
+	t.lock.Lock()
+	items := t.items
+	t.items = nil
+	t.lock.Unlock()
+	if len(items) == 0 {
+		return
+	}
+
+	log.Info(t.name, " hand over ", len(items), " messages")
+	next.lock.Lock()
+	defer next.lock.Unlock()
+	if older {
+		next.items = append(items, next.items...)
+	} else {
+		next.items = append(next.items, items...)
+	}
+	if next.size > 0 && len(next.items) > next.size {
+		next.items = next.items[len(next.items)-next.size:]
+	}
+}
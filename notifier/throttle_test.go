@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+func TestThrottleRetry(t *testing.T) {
+	var sent []string
+	fail := 1
+	th := newThrottle("test", 3600, 2, 10, func(payload []byte) error {
+		if fail > 0 {
+			fail--
+			return errors.New("unavailable")
+		}
+		sent = append(sent, string(payload))
+		return nil
+	})
+	defer th.close()
+
+	th.push("a")
+	th.push("b")
+	th.push("c")
+
+	// 第一条失败，放回队列，下次按顺序继续发送
+	th.flush()
+	assert.Equal(t, len(sent), 0)
+	assert.Equal(t, len(th.items), 3)
+	th.flush()
+	assert.Equal(t, sent, []string{`"a"`, `"b"`})
+	th.flush()
+	assert.Equal(t, sent, []string{`"a"`, `"b"`, `"c"`})
+
+	// 一直失败的消息超过重试次数后丢弃
+	fail = maxThrottleRetries
+	th.push("d")
+	for i := 0; i < maxThrottleRetries; i++ {
+		th.flush()
+	}
+	assert.Equal(t, len(th.items), 0)
+	assert.Equal(t, len(sent), 3)
+}
+
+func TestThrottleSize(t *testing.T) {
+	th := newThrottle("test", 3600, 1, 2, func(payload []byte) error { return nil })
+	defer th.close()
+
+	th.push("a")
+	th.push("b")
+	th.push("c")
+	assert.Equal(t, len(th.items), 2)
+	assert.Equal(t, string(th.items[0].payload), `"b"`)
+}
+
+func TestThrottleHandOver(t *testing.T) {
+	var sent []string
+	send := func(payload []byte) error {
+		sent = append(sent, string(payload))
+		return nil
+	}
+
+	// 没有开启队列文件，重新创建后旧队列的消息交给新队列
+	old := newThrottle("handover", 3600, 10, 10, send)
+	old.push("a")
+	old.push("b")
+	th := newThrottle("handover", 3600, 10, 10, send)
+	th.push("c")
+	old.close()
+	th.flush()
+	assert.Equal(t, sent, []string{`"a"`, `"b"`, `"c"`})
+
+	// 新队列创建后又关闭，旧队列继续使用
+	failed := newThrottle("handover", 3600, 10, 10, send)
+	failed.push("d")
+	failed.close()
+	th.flush()
+	assert.Equal(t, sent, []string{`"a"`, `"b"`, `"c"`, `"d"`})
+	th.close()
+	_, exists := throttles["handover"]
+	assert.False(t, exists)
+}
@@ -0,0 +1,206 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// 每个 webhook 通知同时发送的请求数
+	webhookWorkers = 4
+	// 等待发送的请求数，超过则丢弃
+	webhookQueueSize = 1000
+)
+
+// webhookBackoff 第一次重试的间隔
+var webhookBackoff = time.Second
+
+func init() {
+	Register("webhook", newWebhook)
+}
+
+// webhook 将 log 用模板渲染后发送到自定义的地址，由固定数量的 worker 发送
+type webhook struct {
+	filter *config.Filter
+	client *http.Client
+	jobs   chan webhookJob
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type webhookJob struct {
+	sender config.WebhookSender
+	body   []byte
+}
+
+func newWebhook(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	w := &webhook{
+		filter: filter,
+		client: &http.Client{Timeout: time.Second * time.Duration(filter.Webhook.Timeout)},
+		jobs:   make(chan webhookJob, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+
+	w.wg.Add(webhookWorkers)
+	for i := 0; i < webhookWorkers; i++ {
+		go w.work()
+	}
+	return w, nil
+}
+
+func (w *webhook) Name() string {
+	return w.filter.Name + "-webhook"
+}
+
+func (w *webhook) Send(ctx context.Context, batch Batch) error {
+	info := w.filter.Webhook
+	for _, logData := range batch.Logs {
+		if expired(logData, info.IgnoreIfGtSecs) {
+			log.Debug("webhook message expired: ", info.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(info.MatchRegex, logData.Message) {
+			continue
+		}
+
+		for _, sender := range info.Senders {
+			body, err := renderWebhook(sender, logData)
+			if err != nil {
+				log.Error(w.Name(), " render body error: ", err)
+				continue
+			}
+
+			select {
+			case <-w.done:
+				log.Warn(w.Name(), " closed, drop message")
+				return nil
+			default:
+			}
+
+			select {
+			case w.jobs <- webhookJob{sender: sender, body: body}:
+			default:
+				log.Warn(w.Name(), " queue is full, drop message")
+			}
+		}
+	}
+
+	return nil
+}
+
+// work 发送队列中的请求，关闭后把剩下的请求发送完
+func (w *webhook) work() {
+	defer w.wg.Done()
+
+	send := func(job webhookJob) {
+		if err := w.do(job.sender, job.body); err != nil {
+			log.Error(w.Name(), " send error: ", err)
+		}
+	}
+
+	for {
+		select {
+		case job := <-w.jobs:
+			send(job)
+		case <-w.done:
+			for {
+				select {
+				case job := <-w.jobs:
+					send(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// do 发送请求，5xx 或者网络错误时重试，每次间隔翻倍，关闭后不再重试
+func (w *webhook) do(sender config.WebhookSender, body []byte) error {
+	retries := 0
+	if w.filter.Webhook.Retries != nil {
+		retries = *w.filter.Webhook.Retries
+	}
+
+	var err error
+	backoff := webhookBackoff
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			log.Warn(w.Name(), " retry ", i, " after ", backoff, ", last error: ", err)
+			select {
+			case <-time.After(backoff):
+			case <-w.done:
+				return err
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		if retry, err = w.request(sender, body); err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// request 返回是否需要重试
+func (w *webhook) request(sender config.WebhookSender, body []byte) (bool, error) {
+	req, err := http.NewRequest(sender.Method, sender.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range sender.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= 500 {
+		return true, fmt.Errorf("%v %v status: %v, response: %s", sender.Method, sender.URL, resp.StatusCode, respBody)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Errorf("%v %v status: %v, response: %s", sender.Method, sender.URL, resp.StatusCode, respBody)
+	}
+
+	return false, nil
+}
+
+// Close 等待队列中的请求发送完
+func (w *webhook) Close() error {
+	close(w.done)
+	w.wg.Wait()
+	return nil
+}
+
+func renderWebhook(sender config.WebhookSender, logData logstash.LogData) ([]byte, error) {
+	tmpl, err := sender.BodyTemplate.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Funcs(fieldFuncs(logData)).Execute(&body, logData); err != nil {
+		return nil, err
+	}
+
+	return body.Bytes(), nil
+}
@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestWebhookRetry(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	count := 0
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	sender := config.WebhookSender{URL: server.URL, Method: "POST"}
+	sender.BodyTemplate = template.Must(template.New("").Funcs(config.WebhookFuncs).Parse(`{"message": {{json .Message}}}`))
+	retries := 3
+	filter := &config.Filter{Webhook: config.WebhookInfo{Timeout: 1, Retries: &retries}}
+	n, _ := newWebhook(nil, filter)
+	defer n.Close()
+
+	b, err := renderWebhook(sender, logstash.LogData{Message: `a "quoted" message`})
+	assert.Nil(t, err)
+	assert.Nil(t, n.(*webhook).do(sender, b))
+	assert.Equal(t, count, 3)
+	assert.Equal(t, body, `{"message": "a \"quoted\" message"}`)
+}
+
+func TestWebhookNoRetryOn4xx(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	retries := 3
+	filter := &config.Filter{Webhook: config.WebhookInfo{Timeout: 1, Retries: &retries}}
+	n, _ := newWebhook(nil, filter)
+	defer n.Close()
+	err := n.(*webhook).do(config.WebhookSender{URL: server.URL, Method: "POST"}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, count, 1)
+}
+
+func TestWebhookNoRetry(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	retries := 0
+	filter := &config.Filter{Webhook: config.WebhookInfo{Timeout: 1, Retries: &retries}}
+	n, _ := newWebhook(nil, filter)
+	defer n.Close()
+	err := n.(*webhook).do(config.WebhookSender{URL: server.URL, Method: "POST"}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, count, 1)
+}
+
+func TestWebhookClose(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+	}))
+	defer server.Close()
+
+	sender := config.WebhookSender{URL: server.URL, Method: "POST"}
+	sender.BodyTemplate = template.Must(template.New("").Parse(`{{.Message}}`))
+	retries := 0
+	filter := &config.Filter{Webhook: config.WebhookInfo{IgnoreIfGtSecs: 60, Timeout: 1, Retries: &retries, Senders: []config.WebhookSender{sender}}}
+	n, _ := newWebhook(nil, filter)
+
+	// Close 前放入队列的请求都会发送，之后的丢弃
+	for i := 0; i < 20; i++ {
+		n.Send(context.Background(), Batch{Logs: []logstash.LogData{{Message: "a", Timestamp: time.Now()}}})
+	}
+	assert.Nil(t, n.Close())
+	assert.Equal(t, atomic.LoadInt32(&count), int32(20))
+
+	n.Send(context.Background(), Batch{Logs: []logstash.LogData{{Message: "a", Timestamp: time.Now()}}})
+	assert.Equal(t, atomic.LoadInt32(&count), int32(20))
+}
+
+func TestRenderWebhookField(t *testing.T) {
+	sender := config.WebhookSender{}
+	sender.BodyTemplate = template.Must(template.New("").Funcs(config.WebhookFuncs).Parse(`{"pod": {{json (field "kubernetes.pod.name")}}, "trace": "{{field "trace_id"}}"}`))
+
+	logData := logstash.LogData{Fields: map[string]interface{}{"kubernetes": map[string]interface{}{"pod": map[string]interface{}{"name": "api-1"}}}}
+	b, err := renderWebhook(sender, logData)
+	assert.Nil(t, err)
+	assert.Equal(t, string(b), `{"pod": "api-1", "trace": ""}`)
+}
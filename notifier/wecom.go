@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sdvdxl/go-tools/encrypt"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+const (
+	// 企业微信文本消息最大长度，markdown 为 4096
+	wecomTextLimit = 2048
+)
+
+func init() {
+	Register("wecom", newWeCom)
+}
+
+type wecom struct {
+	filter *config.Filter
+	// 每个 webhook 一个队列
+	throttles []*throttle
+}
+
+func newWeCom(cfg *config.Config, filter *config.Filter) (Notifier, error) {
+	w := &wecom{filter: filter}
+	for _, sender := range filter.WeCom.Senders {
+		url := sender.WebhookURL
+		w.throttles = append(w.throttles, newThrottle(w.Name()+"-"+encrypt.MD5([]byte(url)), filter.WeCom.Interval, filter.WeCom.Limit, cfg.Queue.MaxSize, func(payload []byte) error {
+			return postErrCode(url, json.RawMessage(payload))
+		}))
+	}
+	return w, nil
+}
+
+func (w *wecom) Name() string {
+	return w.filter.Name + "-wecom"
+}
+
+func (w *wecom) Send(ctx context.Context, batch Batch) error {
+	info := w.filter.WeCom
+	for _, logData := range batch.Logs {
+		if expired(logData, info.IgnoreIfGtSecs) {
+			log.Debug("wecom message expired: ", info.IgnoreIfGtSecs)
+			continue
+		}
+
+		if !match(info.MatchRegex, logData.Message) {
+			continue
+		}
+
+		if info.MsgType == "markdown" {
+			w.push(map[string]interface{}{
+				"msgtype":  "markdown",
+				"markdown": map[string]interface{}{"content": truncate(wecomMarkdown(logData), info.MaxLength)},
+			})
+
+			// markdown 不支持通过手机号 @，另外发送一条文本消息
+			if len(info.MentionedMobiles) > 0 {
+				w.pushText(fmt.Sprint("[", logData.Level, "] ", appName(logData)))
+			}
+			continue
+		}
+
+		w.pushText(getMessage(logData, false))
+	}
+
+	return nil
+}
+
+func (w *wecom) SendText(ctx context.Context, text string) error {
+	w.pushText(text)
+	return nil
+}
+
+func (w *wecom) pushText(text string) {
+	limit := w.filter.WeCom.MaxLength
+	if limit > wecomTextLimit {
+		limit = wecomTextLimit
+	}
+	content := map[string]interface{}{"content": truncate(text, limit)}
+	if len(w.filter.WeCom.MentionedMobiles) > 0 {
+		content["mentioned_mobile_list"] = w.filter.WeCom.MentionedMobiles
+	}
+
+	w.push(map[string]interface{}{"msgtype": "text", "text": content})
+}
+
+func (w *wecom) push(payload map[string]interface{}) {
+	for _, t := range w.throttles {
+		t.push(payload)
+	}
+}
+
+func (w *wecom) Close() error {
+	for _, t := range w.throttles {
+		t.close()
+	}
+	return nil
+}
+
+// wecomMarkdown 企业微信只支持部分 markdown 语法，颜色只有 info，comment，warning 三种
+func wecomMarkdown(logData logstash.LogData) string {
+	color := "info"
+	switch strings.ToUpper(logData.Level) {
+	case "FATAL", "ERROR", "WARN", "WARNING":
+		color = "warning"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### <font color=\"%v\">[%v]</font> %v\n", color, logData.Level, appName(logData))
+	fmt.Fprintf(&b, "> Host: <font color=\"comment\">%v</font>\n", logData.Beat.Hostname)
+	fmt.Fprintf(&b, "> Tags: <font color=\"comment\">%v</font>\n", strings.Join(logData.Tags, ", "))
+	fmt.Fprintf(&b, "> LogFile: <font color=\"comment\">%v</font>\n", logData.Source)
+	fmt.Fprintf(&b, "> Timestamp: <font color=\"comment\">%v</font>\n\n", logData.Timestamp)
+	b.WriteString(cutStack(logData.Message))
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/config"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestWeComPayload(t *testing.T) {
+	server, r := newRecorder(`{"errcode":0,"errmsg":"ok"}`)
+	defer server.Close()
+
+	filter := &config.Filter{Name: "test", WeCom: config.WeComInfo{
+		IgnoreIfGtSecs:   60,
+		MsgType:          "markdown",
+		MaxLength:        4096,
+		MentionedMobiles: []string{"13800000000"},
+		Interval:         3600,
+		Limit:            10,
+		Senders:          []config.WeComSender{{WebhookURL: server.URL}},
+	}}
+	n, err := newWeCom(&config.Config{}, filter)
+	assert.Nil(t, err)
+	w := n.(*wecom)
+	defer w.Close()
+
+	logData := logstash.LogData{Message: "timeout", Level: "ERROR", App: "api", Timestamp: time.Now()}
+	assert.Nil(t, w.Send(context.Background(), Batch{Filter: filter, Logs: []logstash.LogData{logData}}))
+	w.throttles[0].flush()
+
+	// markdown 不能 @，另外发送一条文本
+	assert.Equal(t, len(r.bodies), 2)
+	assert.Equal(t, r.bodies[0]["msgtype"], "markdown")
+	content := r.bodies[0]["markdown"].(map[string]interface{})["content"].(string)
+	assert.True(t, strings.HasPrefix(content, `### <font color="warning">[ERROR]</font> api`))
+	assert.Equal(t, r.bodies[1]["msgtype"], "text")
+	text := r.bodies[1]["text"].(map[string]interface{})
+	assert.Equal(t, text["content"], "[ERROR] api")
+	assert.Equal(t, text["mentioned_mobile_list"], []interface{}{"13800000000"})
+
+	// 文本消息最多 2048 字节
+	assert.Nil(t, w.SendText(context.Background(), strings.Repeat("a", 3000)))
+	w.throttles[0].flush()
+	assert.Equal(t, len(r.bodies), 3)
+	assert.Equal(t, r.bodies[2]["text"].(map[string]interface{})["content"], strings.Repeat("a", 2048))
+
+	// errcode 不为 0 时返回错误
+	errServer, _ := newRecorder(`{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	defer errServer.Close()
+	assert.NotNil(t, postErrCode(errServer.URL, map[string]interface{}{}))
+}
@@ -0,0 +1,222 @@
+package queue
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// countsBucket 保存每个队列的条数
+var countsBucket = []byte("\x00counts")
+
+var (
+	lock      sync.Mutex
+	db        *bolt.DB
+	retention time.Duration
+	maxSize   int
+)
+
+// Entry 队列中的一条记录
+type Entry struct {
+	ID   uint64
+	Time time.Time
+	Data []byte
+}
+
+// Queue 持久化的队列，每个通知一个，nil 表示未开启持久化，所有操作都不做任何事情
+type Queue struct {
+	name []byte
+}
+
+// Open 打开队列文件，retentionSecs 秒以前的记录在加载时丢弃，每个队列最多保留 size 条
+func Open(path string, retentionSecs int64, size int) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	d, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	db = d
+	retention = time.Second * time.Duration(retentionSecs)
+	maxSize = size
+	return nil
+}
+
+// Close 关闭队列文件
+func Close() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if db == nil {
+		return nil
+	}
+
+	err := db.Close()
+	db = nil
+	return err
+}
+
+// Get 获取队列，未打开队列文件则返回 nil
+func Get(name string) *Queue {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if db == nil {
+		return nil
+	}
+
+	return &Queue{name: []byte(name)}
+}
+
+// Push 写入一条记录，超过最大条数则删除最早的记录。
+// 并发的写入会合并到一个事务中，减少 fsync
+func (q *Queue) Push(data []byte) (uint64, error) {
+	if q == nil {
+		return 0, nil
+	}
+
+	var id uint64
+	err := db.Batch(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(q.name)
+		if err != nil {
+			return err
+		}
+
+		n := q.count(tx, b)
+		if maxSize > 0 && n >= uint64(maxSize) {
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && n >= uint64(maxSize); k, _ = c.First() {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+				n--
+			}
+		}
+
+		if id, err = b.NextSequence(); err != nil {
+			return err
+		}
+
+		value := make([]byte, 8+len(data))
+		binary.BigEndian.PutUint64(value, uint64(time.Now().UnixNano()))
+		copy(value[8:], data)
+		if err := b.Put(itob(id), value); err != nil {
+			return err
+		}
+
+		return q.setCount(tx, n+1)
+	})
+
+	return id, err
+}
+
+// Load 按写入顺序加载所有记录，过期的记录会被删除
+func (q *Queue) Load() ([]Entry, error) {
+	if q == nil {
+		return nil, nil
+	}
+
+	var entries []Entry
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(q.name)
+		if b == nil {
+			return nil
+		}
+
+		var expired [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if len(v) < 8 {
+				expired = append(expired, k)
+				continue
+			}
+
+			t := time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+			if retention > 0 && time.Since(t) > retention {
+				expired = append(expired, k)
+				continue
+			}
+
+			data := make([]byte, len(v)-8)
+			copy(data, v[8:])
+			entries = append(entries, Entry{ID: binary.BigEndian.Uint64(k), Time: t, Data: data})
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		// 顺便修正条数
+		return q.setCount(tx, uint64(len(entries)))
+	})
+
+	return entries, err
+}
+
+// Remove 删除已经发送的记录
+func (q *Queue) Remove(ids ...uint64) error {
+	if q == nil || len(ids) == 0 {
+		return nil
+	}
+
+	return db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(q.name)
+		if b == nil {
+			return nil
+		}
+
+		n := q.count(tx, b)
+		for _, id := range ids {
+			k := itob(id)
+			if b.Get(k) == nil {
+				continue
+			}
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			if n > 0 {
+				n--
+			}
+		}
+
+		return q.setCount(tx, n)
+	})
+}
+
+// count 队列的条数，保存在 counts bucket 中，避免每次遍历整个队列，
+// 没有保存过时（旧版本的队列文件）遍历一次
+func (q *Queue) count(tx *bolt.Tx, b *bolt.Bucket) uint64 {
+	if counts := tx.Bucket(countsBucket); counts != nil {
+		if v := counts.Get(q.name); len(v) == 8 {
+			return binary.BigEndian.Uint64(v)
+		}
+	}
+
+	return uint64(b.Stats().KeyN)
+}
+
+func (q *Queue) setCount(tx *bolt.Tx, n uint64) error {
+	counts, err := tx.CreateBucketIfNotExists(countsBucket)
+	if err != nil {
+		return err
+	}
+	return counts.Put(q.name, itob(n))
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+func TestQueue(t *testing.T) {
+	assert.True(t, Get("test") == nil)
+	var nilQueue *Queue
+	_, err := nilQueue.Push([]byte("a"))
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "queue")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, Open(filepath.Join(dir, "queue.db"), 3600, 3))
+	defer Close()
+
+	q := Get("test")
+	for _, s := range []string{"a", "b", "c", "d"} {
+		_, err := q.Push([]byte(s))
+		assert.Nil(t, err)
+	}
+
+	// 最多保留 3 条
+	entries, err := q.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, len(entries), 3)
+	assert.Equal(t, string(entries[0].Data), "b")
+	assert.Equal(t, string(entries[2].Data), "d")
+
+	assert.Nil(t, q.Remove(entries[0].ID, entries[1].ID))
+	entries, err = q.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, string(entries[0].Data), "d")
+}
+
+func TestQueueCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "queue.db")
+	assert.Nil(t, Open(path, 3600, 3))
+
+	q := Get("test")
+	var ids []uint64
+	for _, s := range []string{"a", "b", "c"} {
+		id, err := q.Push([]byte(s))
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	// 删除后条数减少，不存在的 id 不影响条数
+	assert.Nil(t, q.Remove(ids[0], ids[1], 100))
+	for _, s := range []string{"d", "e"} {
+		_, err := q.Push([]byte(s))
+		assert.Nil(t, err)
+	}
+
+	entries, err := q.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, len(entries), 3)
+	assert.Equal(t, string(entries[0].Data), "c")
+
+	// 重新打开后条数不变
+	assert.Nil(t, Close())
+	assert.Nil(t, Open(path, 3600, 3))
+	defer Close()
+	q = Get("test")
+	_, err = q.Push([]byte("f"))
+	assert.Nil(t, err)
+
+	entries, err = q.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, len(entries), 3)
+	assert.Equal(t, string(entries[0].Data), "d")
+	assert.Equal(t, string(entries[2].Data), "f")
+}
@@ -0,0 +1,69 @@
+package rate
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// 超过这个数量的 key 时清理过期的 key
+	sweepSize = 1000
+	// 每个 key 最多保留的事件数，超过则丢弃最早的，计数最多为这个值
+	maxEvents = 10000
+)
+
+// keyEvents 一个 key 的事件和它的窗口，不同 key 的窗口可以不同
+type keyEvents struct {
+	window time.Duration
+	times  []time.Time
+}
+
+// Window 滑动窗口计数，按 key 分别计数
+type Window struct {
+	lock   sync.Mutex
+	events map[string]*keyEvents
+}
+
+// NewWindow 创建滑动窗口
+func NewWindow() *Window {
+	return &Window{events: make(map[string]*keyEvents)}
+}
+
+// Add 记录一次事件，返回 key 在 now 之前 window 时间内的事件数，包括本次
+func (w *Window) Add(key string, now time.Time, window time.Duration) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	e := w.events[key]
+	if e == nil {
+		e = &keyEvents{}
+		w.events[key] = e
+	}
+	e.window = window
+	e.times = append(expire(e.times, now, window), now)
+	if len(e.times) > maxEvents {
+		e.times = append(e.times[:0], e.times[len(e.times)-maxEvents:]...)
+	}
+
+	if len(w.events) > sweepSize {
+		// 每个 key 按自己的窗口清理
+		for k, v := range w.events {
+			if v.times = expire(v.times, now, v.window); len(v.times) == 0 {
+				delete(w.events, k)
+			}
+		}
+	}
+
+	return len(e.times)
+}
+
+// expire 去掉窗口以外的事件，事件是按时间顺序加入的
+func expire(events []time.Time, now time.Time, window time.Duration) []time.Time {
+	start := now.Add(-window)
+	i := 0
+	for i < len(events) && !events[i].After(start) {
+		i++
+	}
+
+	return events[i:]
+}
@@ -0,0 +1,41 @@
+package rate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+func TestWindow(t *testing.T) {
+	w := NewWindow()
+	now := time.Now()
+	assert.Equal(t, w.Add("a", now, time.Minute), 1)
+	assert.Equal(t, w.Add("a", now.Add(10*time.Second), time.Minute), 2)
+	assert.Equal(t, w.Add("b", now.Add(20*time.Second), time.Minute), 1)
+	assert.Equal(t, w.Add("a", now.Add(30*time.Second), time.Minute), 3)
+	// 第一次事件已经在窗口之外
+	assert.Equal(t, w.Add("a", now.Add(60*time.Second), time.Minute), 3)
+	assert.Equal(t, w.Add("a", now.Add(200*time.Second), time.Minute), 1)
+}
+
+func TestWindowSweep(t *testing.T) {
+	w := NewWindow()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		w.Add("hour", now.Add(time.Duration(i)*time.Minute), time.Hour)
+	}
+
+	// key 很多时清理，不能用短的窗口清理长窗口的 key
+	for i := 0; i <= sweepSize; i++ {
+		w.Add(fmt.Sprint("key", i), now.Add(10*time.Minute), time.Minute)
+	}
+	assert.Equal(t, w.Add("hour", now.Add(20*time.Minute), time.Hour), 4)
+
+	// 每个 key 最多保留 maxEvents 个事件
+	for i := 0; i < maxEvents+10; i++ {
+		w.Add("many", now, time.Hour)
+	}
+	assert.Equal(t, w.Add("many", now, time.Hour), maxEvents)
+}
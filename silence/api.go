@@ -0,0 +1,56 @@
+package silence
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Routes 注册静默的 http 接口
+//
+//	POST   /api/silences      创建
+//	GET    /api/silences      列表，active=true 只返回生效中的
+//	DELETE /api/silences/:id  立即过期
+func Routes(engine *echo.Echo, store *Store) {
+	g := engine.Group("/api/silences")
+
+	g.POST("", func(c echo.Context) error {
+		var sil Silence
+		if err := c.Bind(&sil); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := store.Add(&sil); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, sil)
+	})
+
+	g.GET("", func(c echo.Context) error {
+		silences := store.List()
+		if c.QueryParam("active") == "true" {
+			now := time.Now()
+			active := silences[:0]
+			for _, sil := range silences {
+				if sil.Active(now) {
+					active = append(active, sil)
+				}
+			}
+			silences = active
+		}
+
+		return c.JSON(http.StatusOK, silences)
+	})
+
+	g.DELETE("/:id", func(c echo.Context) error {
+		if err := store.Expire(c.Param("id")); err == ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		} else if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+}
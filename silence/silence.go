@@ -0,0 +1,248 @@
+package silence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sdvdxl/logstash-http-push/log"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+// 过期超过这个时间的静默在保存时删除
+const keepExpired = 7 * 24 * time.Hour
+
+// ErrNotFound 静默不存在
+var ErrNotFound = errors.New("silence not found")
+
+// Matcher 匹配条件，所有不为空的条件都匹配才算匹配
+type Matcher struct {
+	Tags         []string `json:"tags"` // log 需要包含所有的 tag
+	Level        string   `json:"level"`
+	Host         string   `json:"host"`
+	Source       string   `json:"source"`
+	MessageRegex string   `json:"messageRegex"`
+	messageRegex *regexp.Regexp
+}
+
+// Silence 静默，在 StartsAt 和 EndsAt 之间匹配的 log 不发送通知
+type Silence struct {
+	ID        string    `json:"id"`
+	Matcher   Matcher   `json:"matcher"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Active 是否在生效时间内
+func (s *Silence) Active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Match 是否匹配 log
+func (s *Silence) Match(logData logstash.LogData) bool {
+	m := s.Matcher
+	for _, tag := range m.Tags {
+		found := false
+		for _, t := range logData.Tags {
+			if strings.EqualFold(t, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if m.Level != "" && !strings.EqualFold(m.Level, logData.Level) {
+		return false
+	}
+	if m.Host != "" && m.Host != logData.Beat.Hostname {
+		return false
+	}
+	if m.Source != "" && m.Source != logData.Source {
+		return false
+	}
+	if m.messageRegex != nil && !m.messageRegex.MatchString(logData.Message) {
+		return false
+	}
+
+	return true
+}
+
+// check 检查并编译正则
+func (s *Silence) check() error {
+	m := &s.Matcher
+	if len(m.Tags) == 0 && m.Level == "" && m.Host == "" && m.Source == "" && m.MessageRegex == "" {
+		return errors.New("matcher is empty")
+	}
+
+	if s.EndsAt.IsZero() || !s.EndsAt.After(s.StartsAt) {
+		return errors.New("endsAt must after startsAt")
+	}
+
+	if s.CreatedBy == "" {
+		return errors.New("createdBy is empty")
+	}
+
+	if m.MessageRegex != "" {
+		re, err := regexp.Compile(m.MessageRegex)
+		if err != nil {
+			return err
+		}
+		m.messageRegex = re
+	}
+
+	return nil
+}
+
+// Store 保存在本地文件中的静默
+type Store struct {
+	lock     sync.RWMutex
+	path     string
+	silences []*Silence
+}
+
+// Open 从文件中加载静默，文件不存在则创建空的
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var silences []*Silence
+	if err := json.Unmarshal(data, &silences); err != nil {
+		return nil, err
+	}
+
+	// 错误的静默只忽略，不影响启动
+	for _, sil := range silences {
+		if err := sil.check(); err != nil {
+			log.Warn("ignore invalid silence ", sil.ID, ": ", err)
+			continue
+		}
+		s.silences = append(s.silences, sil)
+	}
+
+	return s, nil
+}
+
+// Add 添加静默，StartsAt 为空则从现在开始
+func (s *Store) Add(sil *Silence) error {
+	now := time.Now()
+	if sil.StartsAt.IsZero() {
+		sil.StartsAt = now
+	}
+	if err := sil.check(); err != nil {
+		return err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	sil.ID = hex.EncodeToString(id)
+	sil.CreatedAt = now
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.save(append(s.silences[:len(s.silences):len(s.silences)], sil), now)
+}
+
+// List 所有的静默，包括已经过期的
+func (s *Store) List() []Silence {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := make([]Silence, 0, len(s.silences))
+	for _, sil := range s.silences {
+		result = append(result, *sil)
+	}
+	return result
+}
+
+// Expire 将静默设为立即过期，还没有开始的直接删除
+func (s *Store) Expire(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for i, sil := range s.silences {
+		if sil.ID != id {
+			continue
+		}
+
+		silences := make([]*Silence, 0, len(s.silences))
+		silences = append(silences, s.silences[:i]...)
+		if now.After(sil.StartsAt) {
+			expired := *sil
+			if expired.EndsAt.After(now) {
+				expired.EndsAt = now
+			}
+			silences = append(silences, &expired)
+		}
+		silences = append(silences, s.silences[i+1:]...)
+		return s.save(silences, now)
+	}
+
+	return ErrNotFound
+}
+
+// Silenced 返回匹配 log 的生效中的静默，没有则返回 nil
+func (s *Store) Silenced(logData logstash.LogData, now time.Time) *Silence {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, sil := range s.silences {
+		if sil.Active(now) && sil.Match(logData) {
+			return sil
+		}
+	}
+
+	return nil
+}
+
+// save 删除过期太久的静默，写入临时文件后再重命名，避免写入一半，
+// 保存成功后才替换内存中的静默，失败时内存和文件保持一致
+func (s *Store) save(all []*Silence, now time.Time) error {
+	silences := make([]*Silence, 0, len(all))
+	for _, sil := range all {
+		if now.Sub(sil.EndsAt) < keepExpired {
+			silences = append(silences, sil)
+		}
+	}
+
+	data, err := json.MarshalIndent(silences, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.silences = silences
+	return nil
+}
@@ -0,0 +1,105 @@
+package silence
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+	"github.com/sdvdxl/logstash-http-push/logstash"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silence")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "silences.json")
+	store, err := Open(path)
+	assert.Nil(t, err)
+
+	now := time.Now()
+	assert.NotNil(t, store.Add(&Silence{EndsAt: now.Add(time.Hour), CreatedBy: "ops"}))
+
+	sil := &Silence{
+		Matcher:   Matcher{Tags: []string{"taga"}, Level: "error", MessageRegex: "Broken pipe"},
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "ops",
+		Comment:   "deploy",
+	}
+	assert.Nil(t, store.Add(sil))
+
+	logData := logstash.LogData{Level: "ERROR", Tags: []string{"TAGA", "console"}, Message: "java.io.IOException: Broken pipe"}
+	assert.NotNil(t, store.Silenced(logData, now.Add(time.Minute)))
+	assert.True(t, store.Silenced(logData, now.Add(2*time.Hour)) == nil)
+
+	logData.Level = "WARN"
+	assert.True(t, store.Silenced(logData, now.Add(time.Minute)) == nil)
+	logData.Level = "ERROR"
+
+	// 重新加载
+	store, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, len(store.List()), 1)
+	assert.NotNil(t, store.Silenced(logData, now.Add(time.Minute)))
+
+	assert.Nil(t, store.Expire(sil.ID))
+	assert.True(t, store.Silenced(logData, time.Now().Add(time.Second)) == nil)
+	assert.Equal(t, store.Expire("unknown"), ErrNotFound)
+}
+
+func TestStoreExpireFuture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silence")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "silences.json")
+	store, err := Open(path)
+	assert.Nil(t, err)
+
+	// 还没有开始的静默过期后直接删除，重新加载不会失败
+	now := time.Now()
+	future := &Silence{Matcher: Matcher{Level: "error"}, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour), CreatedBy: "ops"}
+	started := &Silence{Matcher: Matcher{Level: "warn"}, EndsAt: now.Add(time.Hour), CreatedBy: "ops"}
+	assert.Nil(t, store.Add(future))
+	assert.Nil(t, store.Add(started))
+	assert.Nil(t, store.Expire(future.ID))
+	assert.Equal(t, len(store.List()), 1)
+
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, store.Expire(started.ID))
+	store, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, len(store.List()), 1)
+	assert.False(t, store.List()[0].Active(time.Now()))
+
+	// 错误的静默忽略，其他的正常加载
+	data := `[{"id":"bad","matcher":{"level":"error"},"startsAt":"2020-01-02T00:00:00Z","endsAt":"2020-01-01T00:00:00Z","createdBy":"ops"},
+	{"id":"good","matcher":{"level":"error"},"startsAt":"2020-01-01T00:00:00Z","endsAt":"2099-01-01T00:00:00Z","createdBy":"ops"}]`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(data), 0644))
+	store, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, len(store.List()), 1)
+	assert.Equal(t, store.List()[0].ID, "good")
+}
+
+func TestStoreSaveFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silence")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "silences.json"))
+	assert.Nil(t, err)
+
+	// 目录是一个文件，保存失败
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+	store.path = filepath.Join(file, "silences.json")
+
+	sil := &Silence{Matcher: Matcher{Level: "error"}, EndsAt: time.Now().Add(time.Hour), CreatedBy: "ops"}
+	assert.NotNil(t, store.Add(sil))
+	assert.Equal(t, len(store.List()), 0)
+	assert.True(t, store.Silenced(logstash.LogData{Level: "ERROR"}, time.Now()) == nil)
+}
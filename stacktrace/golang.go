@@ -0,0 +1,61 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// panic: runtime error: index out of range [recovered]，fatal error: concurrent map writes
+	goPanic     = regexp.MustCompile(`^\s*(panic|fatal error): (.*?)( \[recovered\])?$`)
+	goGoroutine = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	// main.(*Server).handle(0xc000010000, ...)，created by main.main in goroutine 1
+	goFunc = regexp.MustCompile(`^(?:created by )?(\S+?)(?:\([^()]*\))?(?: in goroutine \d+)?$`)
+	// 	/go/src/app/main.go:12 +0x1d
+	goFile = regexp.MustCompile(`^\t(.+):(\d+)(?: \+0x[0-9a-f]+)?$`)
+)
+
+func parseGo(lines []string) (*Trace, int) {
+	start, goroutine := -1, -1
+	for i, line := range lines {
+		if start < 0 && goPanic.MatchString(line) {
+			start = i
+		}
+		if goGoroutine.MatchString(line) {
+			goroutine = i
+			break
+		}
+	}
+	if goroutine < 0 {
+		return nil, 0
+	}
+	if start < 0 {
+		start = goroutine
+	}
+
+	t := &Trace{Language: "go"}
+	for _, line := range lines[start:goroutine] {
+		// 先打印的 panic 是最早发生的，作为根本原因放在后面
+		if m := goPanic.FindStringSubmatch(line); m != nil {
+			t.Exceptions = append([]*Exception{{Type: m[1], Message: m[2]}}, t.Exceptions...)
+		}
+	}
+	if len(t.Exceptions) == 0 {
+		t.Exceptions = []*Exception{{Type: "goroutine", Message: strings.TrimSuffix(lines[goroutine], ":")}}
+	}
+
+	// 只解析第一个 goroutine，它是出错的 goroutine
+	top := t.Exceptions[0]
+	for i := goroutine + 1; i+1 < len(lines); i += 2 {
+		fn, file := goFunc.FindStringSubmatch(lines[i]), goFile.FindStringSubmatch(lines[i+1])
+		if lines[i] == "" || fn == nil || file == nil {
+			break
+		}
+
+		line, _ := strconv.Atoi(file[2])
+		top.Frames = append(top.Frames, Frame{Func: fn[1], File: file[1], Line: line})
+	}
+
+	return t, start
+}
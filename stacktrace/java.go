@@ -0,0 +1,95 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// at com.foo.Bar.baz(Bar.java:10)，at java.base/java.lang.Thread.run(Thread.java:834)
+	javaFrame = regexp.MustCompile(`^\s+at ([\w$.<>/]+)\((.*?)\)$`)
+	// ... 5 more，... 12 common frames omitted
+	javaMore = regexp.MustCompile(`^\s+\.\.\. \d+ (more|common frames omitted)$`)
+	// java.lang.IllegalStateException: msg，Caused by: java.io.IOException，Suppressed: ...
+	javaHeader = regexp.MustCompile(`^(\s*)(Caused by: |Suppressed: )?([a-zA-Z_$][\w$]*(?:\.[a-zA-Z_$][\w$]*)+)(?:: (.*))?$`)
+)
+
+// 异常消息最多向前查找的行数
+const javaMaxMessageLines = 10
+
+func parseJava(lines []string) (*Trace, int) {
+	first := -1
+	for i, line := range lines {
+		if javaFrame.MatchString(line) {
+			first = i
+			break
+		}
+	}
+	if first < 1 {
+		return nil, 0
+	}
+
+	// 异常消息可能有多行，向前找到异常类型所在的行
+	start := first - 1
+	for i := first - 1; i >= 0 && i >= first-javaMaxMessageLines; i-- {
+		if javaHeader.MatchString(lines[i]) {
+			start = i
+			break
+		}
+	}
+
+	top := javaException(lines[start])
+	if extra := strings.TrimSpace(strings.Join(lines[start+1:first], "\n")); extra != "" {
+		top.Message = strings.TrimSpace(top.Message + "\n" + extra)
+	}
+
+	t := &Trace{Language: "java", Exceptions: []*Exception{top}}
+	chain, cur := top, top
+	for _, line := range lines[first:] {
+		if m := javaFrame.FindStringSubmatch(line); m != nil {
+			cur.Frames = append(cur.Frames, javaFrameOf(m[1], m[2]))
+			continue
+		}
+		if javaMore.MatchString(line) {
+			continue
+		}
+
+		m := javaHeader.FindStringSubmatch(line)
+		switch {
+		case m == nil:
+			// 不是堆栈的内容，忽略
+		case m[2] == "Caused by: " && m[1] == "":
+			chain = javaException(line)
+			cur = chain
+			t.Exceptions = append(t.Exceptions, chain)
+		case m[2] != "":
+			// Suppressed 以及 Suppressed 中的 Caused by 都放到当前异常的 Suppressed 中
+			cur = javaException(line)
+			chain.Suppressed = append(chain.Suppressed, cur)
+		}
+	}
+
+	return t, start
+}
+
+func javaException(line string) *Exception {
+	m := javaHeader.FindStringSubmatch(line)
+	if m == nil {
+		return &Exception{Message: strings.TrimSpace(line)}
+	}
+
+	return &Exception{Type: m[3], Message: strings.TrimSpace(m[4])}
+}
+
+// javaFrameOf location 为 Bar.java:10，Native Method，Unknown Source
+func javaFrameOf(fn, location string) Frame {
+	f := Frame{Func: fn, File: location}
+	if idx := strings.LastIndex(location, ":"); idx > 0 {
+		if line, err := strconv.Atoi(location[idx+1:]); err == nil {
+			f.File, f.Line = location[:idx], line
+		}
+	}
+
+	return f
+}
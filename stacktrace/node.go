@@ -0,0 +1,64 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// at Object.<anonymous> (/app/index.js:3:5)，at /app/index.js:3:5，at new Promise (<anonymous>)
+	nodeFrame = regexp.MustCompile(`^\s+at (?:(.+?) \((.*)\)|(\S+:\d+:\d+))$`)
+	// /app/index.js:3:5
+	nodeLocation = regexp.MustCompile(`^(.*?):(\d+):\d+$`)
+	// TypeError: Cannot read property 'x' of undefined，Error: ENOENT: no such file
+	nodeHeader = regexp.MustCompile(`^([A-Z][\w$]*(?:Error|Exception))(?:: (.*))?$`)
+)
+
+// 异常消息最多向前查找的行数
+const nodeMaxMessageLines = 10
+
+func parseNode(lines []string) (*Trace, int) {
+	first := -1
+	for i, line := range lines {
+		if nodeFrame.MatchString(line) {
+			first = i
+			break
+		}
+	}
+	if first < 1 {
+		return nil, 0
+	}
+
+	start := first - 1
+	for i := first - 1; i >= 0 && i >= first-nodeMaxMessageLines; i-- {
+		if nodeHeader.MatchString(lines[i]) {
+			start = i
+			break
+		}
+	}
+
+	e := &Exception{Message: strings.TrimSpace(lines[start])}
+	if m := nodeHeader.FindStringSubmatch(lines[start]); m != nil {
+		e.Type, e.Message = m[1], strings.TrimSpace(m[2])
+	}
+	if extra := strings.TrimSpace(strings.Join(lines[start+1:first], "\n")); extra != "" {
+		e.Message = strings.TrimSpace(e.Message + "\n" + extra)
+	}
+
+	for _, line := range lines[first:] {
+		m := nodeFrame.FindStringSubmatch(line)
+		if m == nil {
+			break
+		}
+
+		f := Frame{Func: m[1], File: m[2] + m[3]}
+		if l := nodeLocation.FindStringSubmatch(f.File); l != nil {
+			f.File = l[1]
+			f.Line, _ = strconv.Atoi(l[2])
+		}
+		e.Frames = append(e.Frames, f)
+	}
+
+	return &Trace{Language: "node", Exceptions: []*Exception{e}}, start
+}
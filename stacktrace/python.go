@@ -0,0 +1,59 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const pyTraceback = "Traceback (most recent call last):"
+
+var (
+	// File "/app/main.py", line 3, in <module>
+	pyFrame = regexp.MustCompile(`^\s+File "(.+)", line (\d+), in (.+)$`)
+	// ValueError: bad，requests.exceptions.ConnectionError: ...，KeyboardInterrupt
+	pyException = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?::\s?(.*))?$`)
+)
+
+func parsePython(lines []string) (*Trace, int) {
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == pyTraceback {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, 0
+	}
+
+	t := &Trace{Language: "python"}
+	var cur *Exception
+	for _, line := range lines[start:] {
+		switch {
+		case strings.TrimSpace(line) == pyTraceback:
+			cur = &Exception{}
+		case cur == nil:
+			// 异常之间的说明，比如 During handling of the above exception, another exception occurred:
+		case pyFrame.MatchString(line):
+			m := pyFrame.FindStringSubmatch(line)
+			n, _ := strconv.Atoi(m[2])
+			// python 最近的调用在最后
+			cur.Frames = append([]Frame{{Func: m[3], File: m[1], Line: n}}, cur.Frames...)
+		case line == "" || line[0] == ' ' || line[0] == '\t':
+			// 源码行
+		default:
+			if m := pyException.FindStringSubmatch(line); m != nil {
+				cur.Type, cur.Message = m[1], strings.TrimSpace(m[2])
+			} else {
+				cur.Message = line
+			}
+
+			// 先打印的是最早发生的异常，作为根本原因放在后面
+			t.Exceptions = append([]*Exception{cur}, t.Exceptions...)
+			cur = nil
+		}
+	}
+
+	return t, start
+}
@@ -0,0 +1,154 @@
+// Package stacktrace 解析 log 中的堆栈，支持 Java（包括 Caused by 和 Suppressed），
+// Go 的 panic 和 goroutine dump，Python 的 traceback 和 Node 的 Error
+package stacktrace
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// signatureFrames 生成签名时使用的帧数
+const signatureFrames = 5
+
+// Frame 堆栈中的一帧
+type Frame struct {
+	Func string
+	File string
+	Line int // 0 表示未知
+}
+
+func (f Frame) String() string {
+	switch {
+	case f.File == "":
+		return f.Func
+	case f.Line == 0:
+		return fmt.Sprintf("%v (%v)", f.Func, f.File)
+	}
+
+	return fmt.Sprintf("%v (%v:%v)", f.Func, f.File, f.Line)
+}
+
+// Exception 异常，Frames 从最近的调用开始
+type Exception struct {
+	Type       string
+	Message    string
+	Frames     []Frame
+	Suppressed []*Exception // 只有 Java 有
+}
+
+func (e *Exception) String() string {
+	switch {
+	case e.Type == "":
+		return e.Message
+	case e.Message == "":
+		return e.Type
+	}
+
+	return e.Type + ": " + e.Message
+}
+
+// TopFrames 最近的 n 帧
+func (e *Exception) TopFrames(n int) []Frame {
+	if len(e.Frames) < n {
+		return e.Frames
+	}
+
+	return e.Frames[:n]
+}
+
+// Trace 解析后的堆栈
+type Trace struct {
+	Language string // java，go，python，node
+	Head     string // 堆栈前面的内容，比如日志的时间，级别，类名
+	Raw      string // 堆栈的原文
+	// 异常链，第一个为最外层的异常，最后一个为根本原因
+	Exceptions []*Exception
+}
+
+// Top 最外层的异常
+func (t *Trace) Top() *Exception {
+	return t.Exceptions[0]
+}
+
+// RootCause 根本原因，没有异常链时和 Top 相同
+func (t *Trace) RootCause() *Exception {
+	return t.Exceptions[len(t.Exceptions)-1]
+}
+
+// HasCause 是否有异常链
+func (t *Trace) HasCause() bool {
+	return len(t.Exceptions) > 1
+}
+
+// Summary 去掉堆栈帧的内容，包括最外层的异常和根本原因
+func (t *Trace) Summary() string {
+	parts := make([]string, 0, 3)
+	if t.Head != "" {
+		parts = append(parts, t.Head)
+	}
+	parts = append(parts, t.Top().String())
+	if t.HasCause() {
+		parts = append(parts, "Root cause: "+t.RootCause().String())
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// Signature 签名，由异常类型和根本原因的前几帧组成，不包含行号和异常消息，用于生成指纹
+func (t *Trace) Signature() string {
+	var b strings.Builder
+	b.WriteString(t.Language)
+	for _, e := range t.Exceptions {
+		b.WriteString("|")
+		b.WriteString(e.Type)
+	}
+
+	for _, f := range t.RootCause().TopFrames(signatureFrames) {
+		b.WriteString("|")
+		b.WriteString(f.Func)
+		b.WriteString("@")
+		b.WriteString(path.Base(f.File))
+	}
+
+	return b.String()
+}
+
+// parser 从 lines 中解析堆栈，start 为堆栈开始的行，没有堆栈返回 nil
+type parser func(lines []string) (t *Trace, start int)
+
+// 按顺序尝试，Java 和 Node 的帧都以 at 开头，Java 的帧函数名和括号之间没有空格
+var parsers = []parser{parsePython, parseGo, parseJava, parseNode}
+
+// Parse 解析 msg 中的堆栈，没有堆栈返回 nil
+func Parse(msg string) *Trace {
+	lines := strings.Split(strings.Replace(msg, "\r\n", "\n", -1), "\n")
+	for _, p := range parsers {
+		t, start := p(lines)
+		if t == nil || len(t.Exceptions) == 0 {
+			continue
+		}
+
+		t.Head = strings.TrimSpace(strings.Join(lines[:start], "\n"))
+		t.Raw = strings.TrimSpace(strings.Join(lines[start:], "\n"))
+		return t
+	}
+
+	return nil
+}
+
+// Split 将 msg 分为摘要和堆栈，没有堆栈则 stack 为空
+func Split(msg string) (summary, stack string) {
+	t := Parse(msg)
+	if t == nil {
+		return msg, ""
+	}
+
+	return t.Summary(), t.Raw
+}
+
+// Summary 去掉 msg 中的堆栈帧
+func Summary(msg string) string {
+	summary, _ := Split(msg)
+	return summary
+}
@@ -0,0 +1,121 @@
+package stacktrace
+
+import (
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+const javaTrace = `2017-02-10T16:21:28.942+0800 ERROR [http-nio-8080-exec-5] c.f.OrderService:96 - create order at 10:00 failed
+org.springframework.dao.DataAccessException: could not execute statement
+	at org.springframework.orm.jpa.Foo.translate(Foo.java:10)
+	at com.foo.OrderService.create(OrderService.java:96)
+	Suppressed: java.lang.IllegalStateException: rollback failed
+		at com.foo.Tx.rollback(Tx.java:20)
+Caused by: java.sql.SQLException: Connection reset
+	at com.mysql.jdbc.IO.read(IO.java:300)
+	... 5 more
+Caused by: java.net.SocketException: Connection reset
+	at java.base/java.net.SocketInputStream.read(Native Method)
+	at java.net.SocketInputStream.read(SocketInputStream.java:189)
+	... 12 more`
+
+func TestJava(t *testing.T) {
+	tr := Parse(javaTrace)
+	assert.NotNil(t, tr)
+	assert.Equal(t, tr.Language, "java")
+	assert.Equal(t, tr.Head, "2017-02-10T16:21:28.942+0800 ERROR [http-nio-8080-exec-5] c.f.OrderService:96 - create order at 10:00 failed")
+	assert.Equal(t, len(tr.Exceptions), 3)
+	assert.Equal(t, tr.Top().String(), "org.springframework.dao.DataAccessException: could not execute statement")
+	assert.Equal(t, tr.Top().Frames[1], Frame{Func: "com.foo.OrderService.create", File: "OrderService.java", Line: 96})
+	assert.Equal(t, tr.Top().Suppressed[0].Type, "java.lang.IllegalStateException")
+	assert.Equal(t, len(tr.Top().Suppressed[0].Frames), 1)
+	assert.Equal(t, tr.RootCause().String(), "java.net.SocketException: Connection reset")
+	assert.Equal(t, tr.RootCause().Frames[0], Frame{Func: "java.base/java.net.SocketInputStream.read", File: "Native Method"})
+
+	assert.Equal(t, tr.Summary(), tr.Head+"\n"+tr.Top().String()+"\nRoot cause: java.net.SocketException: Connection reset")
+}
+
+func TestGo(t *testing.T) {
+	tr := Parse(`http: panic serving 10.0.0.1:1234
+panic: assignment to entry in nil map [recovered]
+	panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a2b1c]
+
+goroutine 18 [running]:
+main.(*Server).handle(0xc000010000, {0x6b2a80, 0xc0000a0000})
+	/go/src/app/server.go:42 +0x1d
+created by main.main in goroutine 1
+	/go/src/app/main.go:12 +0x65
+
+goroutine 1 [chan receive]:
+main.main()
+	/go/src/app/main.go:20 +0x90`)
+	assert.NotNil(t, tr)
+	assert.Equal(t, tr.Language, "go")
+	assert.Equal(t, tr.Head, "http: panic serving 10.0.0.1:1234")
+	assert.Equal(t, tr.Top().String(), "panic: runtime error: invalid memory address or nil pointer dereference")
+	assert.Equal(t, tr.RootCause().String(), "panic: assignment to entry in nil map")
+	assert.Equal(t, tr.Top().Frames, []Frame{
+		{Func: "main.(*Server).handle", File: "/go/src/app/server.go", Line: 42},
+		{Func: "main.main", File: "/go/src/app/main.go", Line: 12},
+	})
+}
+
+func TestPython(t *testing.T) {
+	tr := Parse(`ERROR worker failed
+Traceback (most recent call last):
+  File "/app/db.py", line 10, in get
+    return cache[key]
+KeyError: 'user:1'
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/app/main.py", line 3, in <module>
+    run()
+  File "/app/main.py", line 8, in run
+    get("user:1")
+ValueError: user not found`)
+	assert.NotNil(t, tr)
+	assert.Equal(t, tr.Language, "python")
+	assert.Equal(t, tr.Head, "ERROR worker failed")
+	assert.Equal(t, tr.Top().String(), "ValueError: user not found")
+	assert.Equal(t, tr.Top().Frames[0], Frame{Func: "run", File: "/app/main.py", Line: 8})
+	assert.Equal(t, tr.RootCause().String(), "KeyError: 'user:1'")
+}
+
+func TestNode(t *testing.T) {
+	tr := Parse(`TypeError: Cannot read property 'id' of undefined
+    at getUser (/app/user.js:3:15)
+    at Array.forEach (<anonymous>)
+    at /app/index.js:10:3`)
+	assert.NotNil(t, tr)
+	assert.Equal(t, tr.Language, "node")
+	assert.Equal(t, tr.Head, "")
+	assert.Equal(t, tr.Top().String(), "TypeError: Cannot read property 'id' of undefined")
+	assert.Equal(t, tr.Top().Frames, []Frame{
+		{Func: "getUser", File: "/app/user.js", Line: 3},
+		{Func: "Array.forEach", File: "<anonymous>"},
+		{File: "/app/index.js", Line: 10},
+	})
+	assert.False(t, tr.HasCause())
+}
+
+func TestNoTrace(t *testing.T) {
+	msg := "2017-02-10 ERROR - meeting at 10:00 canceled, look at the log"
+	assert.True(t, Parse(msg) == nil)
+	assert.Equal(t, Summary(msg), msg)
+
+	summary, stack := Split(javaTrace)
+	assert.Equal(t, summary, Parse(javaTrace).Summary())
+	assert.Equal(t, stack[:44], "org.springframework.dao.DataAccessException:")
+}
+
+func TestSignature(t *testing.T) {
+	a := Parse("java.lang.NullPointerException: a is null\n\tat com.foo.A.run(A.java:10)")
+	b := Parse("java.lang.NullPointerException: b is null\n\tat com.foo.A.run(A.java:12)")
+	c := Parse("java.lang.NullPointerException: a is null\n\tat com.foo.B.run(B.java:10)")
+	assert.Equal(t, a.Signature(), b.Signature())
+	assert.True(t, a.Signature() != c.Signature())
+}